@@ -0,0 +1,9 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "syscall"
+
+// execRuntime replaces the shim with the real runtime.
+var execRuntime = syscall.Exec
@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "fmt"
+
+// execRuntime replaces the shim with the real runtime.
+var execRuntime = func(path string, args []string, env []string) error {
+	return fmt.Errorf("executing %q: unsupported platform", path)
+}
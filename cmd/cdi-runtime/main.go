@@ -0,0 +1,191 @@
+// The cdi-runtime command is an OCI runtime shim which injects CDI
+// devices into a container before passing control to the real runtime.
+// It is invoked in place of runc: on create and run it injects the CDI
+// devices requested by the container into the bundle's config.json, then
+// it executes the real runtime with the original arguments.
+//
+// The real runtime is taken from $CDI_RUNTIME, "runc" by default. The
+// CDI registry is configured by the CDI config file and its environment
+// variable overrides, for instance $CDI_SPEC_DIRS, a colon-separated list
+// of Spec directories. Devices are requested
+// by "cdi.k8s.io/" annotations or by the CDI_DEVICES environment variable
+// of the container process, both comma-separated lists of qualified CDI
+// device names.
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+const (
+	// runtimeEnv is the environment variable naming the real runtime.
+	runtimeEnv = "CDI_RUNTIME"
+	// defaultRuntime is the default real runtime.
+	defaultRuntime = "runc"
+	// devicesEnv is the container environment variable requesting devices.
+	devicesEnv = "CDI_DEVICES"
+	// bundleConfig is the name of the OCI Spec file in a bundle.
+	bundleConfig = "config.json"
+)
+
+// globalValueFlags are the global runc flags taking a separate value.
+var globalValueFlags = map[string]bool{
+	"--root":       true,
+	"--log":        true,
+	"--log-format": true,
+	"--criu":       true,
+	"--rootless":   true,
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "cdi-runtime: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run injects CDI devices if the runtime is invoked to create a container,
+// then executes the real runtime with the given arguments.
+func run(args []string) error {
+	runtime := os.Getenv(runtimeEnv)
+	if runtime == "" {
+		runtime = defaultRuntime
+	}
+	path, err := exec.LookPath(runtime)
+	if err != nil {
+		return fmt.Errorf("failed to find runtime: %w", err)
+	}
+
+	if bundle, ok := createBundle(args); ok {
+		if err := injectBundle(bundle); err != nil {
+			return err
+		}
+	}
+
+	return execRuntime(path, append([]string{runtime}, args...), os.Environ())
+}
+
+// createBundle returns the bundle directory if the arguments invoke the
+// create or run command.
+func createBundle(args []string) (string, bool) {
+	i := 0
+	for ; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			break
+		}
+		if globalValueFlags[arg] {
+			i++
+		}
+	}
+	if i >= len(args) || (args[i] != "create" && args[i] != "run") {
+		return "", false
+	}
+
+	bundle := "."
+	for args = args[i+1:]; len(args) > 0; args = args[1:] {
+		switch arg := args[0]; {
+		case arg == "--":
+			return bundle, true
+		case (arg == "--bundle" || arg == "-b") && len(args) > 1:
+			bundle = args[1]
+			args = args[1:]
+		case strings.HasPrefix(arg, "--bundle="):
+			bundle = strings.TrimPrefix(arg, "--bundle=")
+		case strings.HasPrefix(arg, "-b="):
+			bundle = strings.TrimPrefix(arg, "-b=")
+		}
+	}
+	return bundle, true
+}
+
+// injectBundle injects the CDI devices requested by the container of
+// the bundle into its config.json.
+func injectBundle(bundle string) error {
+	path := filepath.Join(bundle, bundleConfig)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read OCI Spec: %w", err)
+	}
+	spec := &oci.Spec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return fmt.Errorf("failed to parse OCI Spec %q: %w", path, err)
+	}
+
+	devices, err := requestedDevices(spec)
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return nil
+	}
+
+	cache, err := cdi.NewRegistryFromConfig("")
+	if err != nil {
+		return err
+	}
+	if _, err := cache.InjectDevices(spec, devices...); err != nil {
+		return err
+	}
+
+	if data, err = json.Marshal(spec); err != nil {
+		return fmt.Errorf("failed to encode OCI Spec: %w", err)
+	}
+	return writeConfig(path, data)
+}
+
+// requestedDevices returns the devices requested by annotations and by
+// the container environment, without duplicates.
+func requestedDevices(spec *oci.Spec) ([]string, error) {
+	_, requested, err := cdi.ParseAnnotations(spec.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.Process != nil {
+		for _, e := range spec.Process.Env {
+			if value, ok := strings.CutPrefix(e, devicesEnv+"="); ok {
+				requested = append(requested, strings.Split(value, ",")...)
+			}
+		}
+	}
+
+	var (
+		devices []string
+		seen    = map[string]bool{}
+	)
+	for _, d := range requested {
+		if d = strings.TrimSpace(d); d != "" && !seen[d] {
+			seen[d] = true
+			devices = append(devices, d)
+		}
+	}
+	return devices, nil
+}
+
+// writeConfig replaces the bundle config.json with data.
+func writeConfig(path string, data []byte) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to write OCI Spec: %w", err)
+	}
+	tmp := path + ".cdi.tmp"
+	if err := ioutil.WriteFile(tmp, data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write OCI Spec: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write OCI Spec: %w", err)
+	}
+	return nil
+}
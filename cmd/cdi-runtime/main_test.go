@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+func TestCreateBundle(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		bundle string
+		create bool
+	}{
+		{args: []string{"create", "ctr"}, bundle: ".", create: true},
+		{args: []string{"--root", "/run/runc", "create", "--bundle", "/b", "ctr"}, bundle: "/b", create: true},
+		{args: []string{"--debug", "run", "-b=/b", "ctr"}, bundle: "/b", create: true},
+		{args: []string{"create", "--bundle=/b", "--", "-b"}, bundle: "/b", create: true},
+		{args: []string{"--root", "create", "start", "ctr"}},
+		{args: []string{"delete", "--bundle", "/b", "ctr"}},
+		{args: []string{"--version"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			bundle, create := createBundle(tc.args)
+			require.Equal(t, tc.create, create)
+			require.Equal(t, tc.bundle, bundle)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script runtime")
+	}
+
+	dir := t.TempDir()
+	specDir := filepath.Join(dir, "cdi")
+	bundle := filepath.Join(dir, "bundle")
+	require.NoError(t, os.MkdirAll(specDir, 0o755))
+	require.NoError(t, os.MkdirAll(bundle, 0o755))
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(specDir, "vendor.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["DEV0=1"]
+  - name: dev1
+    containerEdits:
+      env: ["DEV1=1"]
+`), 0o644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bundle, "config.json"), []byte(`{
+  "ociVersion": "1.1.0",
+  "process": {"env": ["CDI_DEVICES=vendor.com/device=dev1"]},
+  "annotations": {"cdi.k8s.io/plugin_dev0": "vendor.com/device=dev0"}
+}`), 0o644))
+
+	argsFile := filepath.Join(dir, "args")
+	fakeRuntime := filepath.Join(dir, "fake-runc")
+	require.NoError(t, ioutil.WriteFile(fakeRuntime,
+		[]byte("#!/bin/sh\necho \"$@\" > "+argsFile+"\n"), 0o755))
+
+	t.Setenv(runtimeEnv, fakeRuntime)
+	t.Setenv(cdi.SpecDirsEnv, specDir)
+
+	orig := execRuntime
+	defer func() { execRuntime = orig }()
+	execRuntime = func(path string, args []string, env []string) error {
+		cmd := exec.Command(path, args[1:]...)
+		cmd.Env = env
+		return cmd.Run()
+	}
+
+	args := []string{"--root", "/run/test", "create", "--bundle", bundle, "ctr"}
+	require.NoError(t, run(args))
+
+	recorded, err := ioutil.ReadFile(argsFile)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join(args, " ")+"\n", string(recorded))
+
+	data, err := ioutil.ReadFile(filepath.Join(bundle, "config.json"))
+	require.NoError(t, err)
+	spec := &oci.Spec{}
+	require.NoError(t, json.Unmarshal(data, spec))
+	require.Equal(t, []string{"CDI_DEVICES=vendor.com/device=dev1", "DEV0=1", "DEV1=1"}, spec.Process.Env)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(bundle, "config.json"), []byte(`{
+  "ociVersion": "1.1.0",
+  "annotations": {"cdi.k8s.io/plugin_dev0": "vendor.com/device=dev2"}
+}`), 0o644))
+	require.Error(t, run(args))
+	require.NoError(t, run([]string{"delete", "ctr"}))
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	"sigs.k8s.io/yaml"
+
+	"container-device-interface-aaron/pkg/cdi"
+	specs "container-device-interface-aaron/specs-go"
+)
+
+func init() {
+	register("convert", "convert a CDI Spec file between YAML and JSON", convertCmd)
+}
+
+const (
+	// versionMinimum rewrites cdiVersion to the minimum required version.
+	versionMinimum = "minimum"
+	// versionLatest rewrites cdiVersion to the latest version.
+	versionLatest = "latest"
+)
+
+// convertCmd converts a Spec file to canonically formatted YAML or JSON,
+// optionally rewriting its cdiVersion.
+func convertCmd(args []string, stdout io.Writer) error {
+	var (
+		format      string
+		specVersion string
+	)
+
+	fs := newFlagSet("convert", "<input> [output]")
+	fs.StringVar(&format, "o", "", "output format, 'json' or 'yaml' (default by output extension, or yaml)")
+	fs.StringVar(&specVersion, "version", "",
+		"rewrite cdiVersion, '"+versionMinimum+"', '"+versionLatest+"' or an explicit version")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	input, output := fs.Arg(0), fs.Arg(1)
+	if format == "" {
+		format = formatYAML
+		if filepath.Ext(output) == ".json" {
+			format = formatJSON
+		}
+	}
+	if format != formatJSON && format != formatYAML {
+		return fmt.Errorf("invalid output format %q", format)
+	}
+
+	data, err := ioutil.ReadFile(input)
+	if err != nil {
+		return err
+	}
+	raws, errs := cdi.ParseSpecs(data)
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to parse %q: %w", input, errs[0])
+	}
+	if len(raws) == 0 {
+		return fmt.Errorf("failed to parse %q: no Spec data", input)
+	}
+
+	for _, raw := range raws {
+		if err := convertVersion(raw, specVersion); err != nil {
+			return fmt.Errorf("failed to convert %q: %w", input, err)
+		}
+	}
+
+	data, err = encodeSpecs(raws, format)
+	if err != nil {
+		return fmt.Errorf("failed to convert %q: %w", input, err)
+	}
+
+	if output == "" {
+		_, err = stdout.Write(data)
+		return err
+	}
+	return writeFileAtomic(output, data)
+}
+
+// convertVersion rewrites the cdiVersion of a raw Spec as requested.
+func convertVersion(raw *specs.Spec, specVersion string) error {
+	switch specVersion {
+	case "":
+		return nil
+	case versionLatest:
+		specVersion = cdi.CurrentVersion
+	case versionMinimum:
+		minVersion, err := cdi.MinimumRequiredVersion(raw)
+		if err != nil {
+			return err
+		}
+		specVersion = minVersion
+	}
+	return cdi.UpdateSpecVersion(raw, specVersion)
+}
+
+// encodeSpecs encodes raw Specs canonically in the given format. Multiple
+// Specs are encoded as a multi-document YAML stream or as JSON Lines.
+func encodeSpecs(raws []*specs.Spec, format string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+
+	for i, raw := range raws {
+		data, err := cdi.CanonicalSpec(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case format == formatYAML:
+			if data, err = yaml.JSONToYAML(data); err != nil {
+				return nil, err
+			}
+			if i > 0 {
+				buf.WriteString("---\n")
+			}
+			buf.Write(data)
+		case len(raws) > 1:
+			buf.Write(data)
+			buf.WriteByte('\n')
+		default:
+			if err := json.Indent(buf, data, "", "  "); err != nil {
+				return nil, err
+			}
+			buf.WriteByte('\n')
+		}
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"container-device-interface-aaron/pkg/cdi"
+	specs "container-device-interface-aaron/specs-go"
+)
+
+func init() {
+	register("diff", "show the semantic differences between CDI Specs", diffCmd)
+}
+
+// diffOutput is the output of the diff command.
+type diffOutput struct {
+	Specs []*cdi.SpecDiff `json:"specs"`
+}
+
+// diffCmd compares two Spec files or directories. Like diff(1), it
+// exits with status 1 if there are differences and with status 2 on
+// errors.
+func diffCmd(args []string, stdout io.Writer) error {
+	var format string
+
+	fs := newFlagSet("diff", "<old> <new>")
+	fs.StringVar(&format, "o", formatText, "output format, 'text', 'json' or 'yaml'")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	if err := (&registryFlags{format: format}).validate(); err != nil {
+		return &exitError{status: 2, err: err}
+	}
+
+	from, err := loadRawSpecs(fs.Arg(0))
+	if err != nil {
+		return &exitError{status: 2, err: err}
+	}
+	to, err := loadRawSpecs(fs.Arg(1))
+	if err != nil {
+		return &exitError{status: 2, err: err}
+	}
+
+	diffs, err := cdi.DiffSpecSets(from, to)
+	if err != nil {
+		return &exitError{status: 2, err: err}
+	}
+
+	out := &diffOutput{Specs: diffs}
+	if out.Specs == nil {
+		out.Specs = []*cdi.SpecDiff{}
+	}
+	if err := writeOutput(stdout, format, out, out.writeText); err != nil {
+		return &exitError{status: 2, err: err}
+	}
+	if len(diffs) > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// loadRawSpecs parses all Specs in the given file or directory.
+func loadRawSpecs(path string) ([]*specs.Spec, error) {
+	files, err := collectSpecFiles([]string{path})
+	if err != nil {
+		return nil, err
+	}
+
+	var raws []*specs.Spec
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, errs := cdi.ParseSpecs(data)
+		if len(errs) > 0 {
+			return nil, fmt.Errorf("failed to parse %q: %w", file, errs[0])
+		}
+		raws = append(raws, parsed...)
+	}
+	return raws, nil
+}
+
+// writeText writes the differences in a diff-like text format.
+func (o *diffOutput) writeText(w io.Writer) error {
+	for _, s := range o.Specs {
+		fmt.Fprintf(w, "%s %s\n", diffMark(s.Status), s.Kind)
+		writeChanges(w, "    ", s.Changes)
+		for _, d := range s.Devices {
+			fmt.Fprintf(w, "    %s device %s\n", diffMark(d.Status), d.Name)
+			writeChanges(w, "        ", d.Changes)
+		}
+	}
+	return nil
+}
+
+// writeChanges writes changes with the given indentation.
+func writeChanges(w io.Writer, indent string, changes []*cdi.Change) {
+	for _, c := range changes {
+		field := c.Field
+		if c.Key != "" {
+			field += " " + c.Key
+		}
+		switch c.Status {
+		case cdi.DiffAdded:
+			fmt.Fprintf(w, "%s+ %s: %s\n", indent, field, c.New)
+		case cdi.DiffRemoved:
+			fmt.Fprintf(w, "%s- %s: %s\n", indent, field, c.Old)
+		default:
+			fmt.Fprintf(w, "%s~ %s: %s -> %s\n", indent, field, c.Old, c.New)
+		}
+	}
+}
+
+// diffMark returns the diff-like marker for a status.
+func diffMark(status cdi.DiffStatus) string {
+	switch status {
+	case cdi.DiffAdded:
+		return "+"
+	case cdi.DiffRemoved:
+		return "-"
+	}
+	return "~"
+}
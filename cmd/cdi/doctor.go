@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+func init() {
+	register("doctor", "check CDI devices against the host", doctorCmd)
+}
+
+// doctorOutput is the output of the doctor command.
+type doctorOutput struct {
+	Devices []doctorDevice      `json:"devices"`
+	Errors  map[string][]string `json:"errors,omitempty"`
+}
+
+// doctorDevice is the host check result of a single device.
+type doctorDevice struct {
+	Name     string   `json:"name"`
+	Spec     string   `json:"spec"`
+	Broken   bool     `json:"broken"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// doctorCmd checks every device in the Spec directories against the
+// host and reports broken devices and Spec files which fail to load.
+func doctorCmd(args []string, stdout io.Writer) error {
+	var flags registryFlags
+
+	fs := newFlagSet("doctor", "")
+	flags.add(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if err := flags.validate(); err != nil {
+		return err
+	}
+
+	cache, err := flags.newCache()
+	if err != nil {
+		return err
+	}
+
+	problems := cache.CheckHost()
+	out := &doctorOutput{
+		Devices: []doctorDevice{},
+		Errors:  errorStrings(cache.GetErrors()),
+	}
+	for _, name := range cache.ListDevices() {
+		dev := doctorDevice{
+			Name: name,
+			Spec: cache.GetDevice(name).GetSpec().GetPath(),
+		}
+		for _, err := range problems[name] {
+			dev.Broken = true
+			dev.Problems = append(dev.Problems, err.Error())
+		}
+		out.Devices = append(out.Devices, dev)
+	}
+
+	if err := writeOutput(stdout, flags.format, out, out.writeText); err != nil {
+		return err
+	}
+	if len(problems) > 0 || len(out.Errors) > 0 {
+		return errFailed
+	}
+	return nil
+}
+
+// writeText writes the check results as text.
+func (o *doctorOutput) writeText(w io.Writer) error {
+	for _, dev := range o.Devices {
+		status := "OK    "
+		if dev.Broken {
+			status = "BROKEN"
+		}
+		fmt.Fprintf(w, "%s  %s (%s)\n", status, dev.Name, dev.Spec)
+		for _, p := range dev.Problems {
+			fmt.Fprintf(w, "        %s\n", p)
+		}
+	}
+	if len(o.Errors) > 0 {
+		fmt.Fprintln(w)
+		writeErrors(w, o.Errors)
+	}
+	return nil
+}
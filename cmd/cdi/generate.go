@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"container-device-interface-aaron/pkg/cdi"
+	specs "container-device-interface-aaron/specs-go"
+)
+
+func init() {
+	register("generate", "generate a CDI Spec for host device nodes", generateCmd)
+}
+
+const (
+	// nameByIndex names generated devices by their index.
+	nameByIndex = "index"
+	// nameByBasename names generated devices by their device node name.
+	nameByBasename = "basename"
+)
+
+// stringsFlag is a repeatable string flag.
+type stringsFlag []string
+
+// String returns the values as a comma-separated list.
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+// Set adds a value.
+func (s *stringsFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// generateCmd generates a Spec with one device per host device node
+// matching the given globs.
+func generateCmd(args []string, stdout io.Writer) error {
+	var (
+		vendor, class string
+		globs         stringsFlag
+		mounts        stringsFlag
+		env           stringsFlag
+		naming        string
+		format        string
+		dir           string
+		toOut         bool
+	)
+
+	fs := newFlagSet("generate", "--vendor <vendor> --class <class> --device-glob <glob>...")
+	fs.StringVar(&vendor, "vendor", "", "vendor of the Spec")
+	fs.StringVar(&class, "class", "", "device class of the Spec")
+	fs.Var(&globs, "device-glob", "glob of host device nodes, one device per node (repeatable)")
+	fs.Var(&mounts, "mount", "host path to bind mount read-only, 'path' or 'host:container' (repeatable)")
+	fs.Var(&env, "env", "'NAME=value' for all devices or 'NAME' set to the device name (repeatable)")
+	fs.StringVar(&naming, "name", nameByIndex, "device naming, '"+nameByIndex+"' or '"+nameByBasename+"'")
+	fs.StringVar(&format, "o", formatYAML, "output format, 'json' or 'yaml'")
+	fs.StringVar(&dir, "dir", cdi.DefaultStaticDir, "directory to write the Spec file into")
+	fs.BoolVar(&toOut, "stdout", false, "write the Spec to stdout instead of a file")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if vendor == "" || class == "" || len(globs) == 0 || fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if err := cdi.ValidateVendorName(vendor); err != nil {
+		return err
+	}
+	if err := cdi.ValidateClassName(class); err != nil {
+		return err
+	}
+	if naming != nameByIndex && naming != nameByBasename {
+		return fmt.Errorf("invalid device naming %q", naming)
+	}
+	if format != formatJSON && format != formatYAML {
+		return fmt.Errorf("invalid output format %q", format)
+	}
+
+	raw := &specs.Spec{
+		Kind: vendor + "/" + class,
+	}
+
+	var perDevice []string
+	for _, e := range env {
+		if strings.Contains(e, "=") {
+			raw.ContainerEdits.Env = append(raw.ContainerEdits.Env, e)
+		} else {
+			perDevice = append(perDevice, e)
+		}
+	}
+	for _, m := range mounts {
+		hostPath, containerPath := m, m
+		if i := strings.Index(m, ":"); i >= 0 {
+			hostPath, containerPath = m[:i], m[i+1:]
+		}
+		raw.ContainerEdits.Mounts = append(raw.ContainerEdits.Mounts, &specs.Mount{
+			HostPath:      hostPath,
+			ContainerPath: containerPath,
+			Options:       []string{"ro", "nosuid", "nodev", "bind"},
+		})
+	}
+
+	// paths matching several globs get a single device
+	var (
+		paths   []string
+		matched = map[string]bool{}
+	)
+	for _, glob := range globs {
+		matches, err := filepath.Glob(glob)
+		if err != nil {
+			return fmt.Errorf("invalid device glob %q: %w", glob, err)
+		}
+		for _, path := range matches {
+			path = filepath.Clean(path)
+			if !matched[path] {
+				matched[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	if len(paths) == 0 {
+		return fmt.Errorf("no host devices match %s", strings.Join(globs, ", "))
+	}
+
+	seen := map[string]bool{}
+	for i, path := range paths {
+		node, err := cdi.HostDeviceNode(path)
+		if err != nil {
+			return err
+		}
+		name := strconv.Itoa(i)
+		if naming == nameByBasename {
+			name = filepath.Base(path)
+		}
+		if err := cdi.ValidateDeviceName(name); err != nil {
+			return err
+		}
+		if seen[name] {
+			return fmt.Errorf("duplicate device name %q for %q", name, path)
+		}
+		seen[name] = true
+
+		dev := specs.Device{
+			Name: name,
+			ContainerEdits: specs.ContainerEdits{
+				DeviceNodes: []*specs.DeviceNode{node},
+			},
+		}
+		for _, e := range perDevice {
+			dev.ContainerEdits.Env = append(dev.ContainerEdits.Env, e+"="+name)
+		}
+		raw.Devices = append(raw.Devices, dev)
+	}
+
+	minVersion, err := cdi.MinimumRequiredVersion(raw)
+	if err != nil {
+		return err
+	}
+	raw.Version = minVersion
+
+	data, err := encodeSpecs([]*specs.Spec{raw}, format)
+	if err != nil {
+		return err
+	}
+	if toOut {
+		_, err = stdout.Write(data)
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create Spec directory: %w", err)
+	}
+	path := filepath.Join(dir, cdi.GenerateSpecName(vendor, class)+"."+format)
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "%s\n", path)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+func init() {
+	register("inject", "inject CDI devices into an OCI bundle", injectCmd)
+}
+
+const (
+	// bundleConfig is the name of the OCI Spec file in a bundle.
+	bundleConfig = "config.json"
+)
+
+// injectCmd injects the given qualified devices into the config.json of
+// an OCI bundle, using the same injection path as runtime integrations.
+func injectCmd(args []string, stdout io.Writer) error {
+	var (
+		flags  registryFlags
+		bundle string
+		dryRun bool
+		toOut  bool
+	)
+
+	fs := newFlagSet("inject", "--bundle <dir> <vendor/class=name>...")
+	flags.addSpecDirs(fs)
+	fs.StringVar(&bundle, "bundle", "", "OCI bundle directory")
+	fs.BoolVar(&dryRun, "dry-run", false, "print a diff of the changes instead of writing them")
+	fs.BoolVar(&toOut, "stdout", false, "write the updated OCI Spec to stdout instead of the bundle")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if bundle == "" || fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	path := filepath.Join(bundle, bundleConfig)
+	orig, err := readOCISpec(path)
+	if err != nil {
+		return err
+	}
+	spec, err := readOCISpec(path)
+	if err != nil {
+		return err
+	}
+
+	cache, err := flags.newCache()
+	if err != nil {
+		return err
+	}
+	if _, err := cache.InjectDevices(spec, fs.Args()...); err != nil {
+		return err
+	}
+
+	before, err := marshalOCISpec(orig)
+	if err != nil {
+		return err
+	}
+	after, err := marshalOCISpec(spec)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case dryRun:
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(before)),
+			B:        difflib.SplitLines(string(after)),
+			FromFile: path,
+			ToFile:   path,
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(stdout, diff)
+		return err
+	case toOut:
+		_, err := stdout.Write(after)
+		return err
+	}
+
+	return writeFileAtomic(path, after)
+}
+
+// readOCISpec reads an OCI Spec from the given file.
+func readOCISpec(path string) (*oci.Spec, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read OCI Spec: %w", err)
+	}
+	spec := &oci.Spec{}
+	if err := json.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("failed to parse OCI Spec %q: %w", path, err)
+	}
+	return spec, nil
+}
+
+// marshalOCISpec encodes an OCI Spec the way runc formats config.json.
+func marshalOCISpec(spec *oci.Spec) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(spec); err != nil {
+		return nil, fmt.Errorf("failed to encode OCI Spec: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+func init() {
+	register("list", "list CDI vendors, classes and devices", listCmd)
+}
+
+// listOutput is the output of the list command.
+type listOutput struct {
+	Vendors []string            `json:"vendors"`
+	Classes []string            `json:"classes"`
+	Devices []listDevice        `json:"devices"`
+	Errors  map[string][]string `json:"errors,omitempty"`
+}
+
+// listDevice describes a single device and its origin.
+type listDevice struct {
+	Name     string `json:"name"`
+	Spec     string `json:"spec"`
+	Priority int    `json:"priority"`
+}
+
+// listCmd lists the vendors, classes and devices found in the Spec
+// directories, together with the Spec file defining each device.
+func listCmd(args []string, stdout io.Writer) error {
+	var flags registryFlags
+
+	fs := newFlagSet("list", "")
+	flags.add(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return errUsage
+	}
+	if err := flags.validate(); err != nil {
+		return err
+	}
+
+	cache, err := flags.newCache()
+	if err != nil {
+		return err
+	}
+
+	out := &listOutput{
+		Vendors: cache.ListVendors(),
+		Classes: cache.ListClasses(),
+		Devices: []listDevice{},
+		Errors:  errorStrings(cache.GetErrors()),
+	}
+	for _, name := range cache.ListDevices() {
+		dev := cache.GetDevice(name)
+		out.Devices = append(out.Devices, listDevice{
+			Name:     name,
+			Spec:     dev.GetSpec().GetPath(),
+			Priority: dev.GetSpec().GetPriority(),
+		})
+	}
+
+	return writeOutput(stdout, flags.format, out, out.writeText)
+}
+
+// writeText writes the list in human-readable form.
+func (o *listOutput) writeText(w io.Writer) error {
+	fmt.Fprintln(w, "Vendors:")
+	for _, vendor := range o.Vendors {
+		fmt.Fprintf(w, "  %s\n", vendor)
+	}
+	fmt.Fprintln(w, "Classes:")
+	for _, class := range o.Classes {
+		fmt.Fprintf(w, "  %s\n", class)
+	}
+	fmt.Fprintln(w, "Devices:")
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	for _, dev := range o.Devices {
+		fmt.Fprintf(tw, "  %s\t%s\tpriority %d\n", dev.Name, dev.Spec, dev.Priority)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	writeErrors(w, o.Errors)
+	return nil
+}
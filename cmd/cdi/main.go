@@ -0,0 +1,155 @@
+// The cdi command is a tool for working with CDI Spec files.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// command is a cdi subcommand.
+type command struct {
+	// usage is a one-line description of the command.
+	usage string
+	// run runs the command with the given arguments.
+	run func(args []string, stdout io.Writer) error
+}
+
+var (
+	// commands are the known subcommands.
+	commands = map[string]*command{}
+
+	// errFailed is returned by commands which already reported their
+	// failures, only the exit status is left to set.
+	errFailed = errors.New("command failed")
+	// errUsage is returned by commands invoked with invalid arguments.
+	errUsage = errors.New("invalid usage")
+)
+
+// exitError is an error of a command which exits with a status other
+// than 1, for instance diff which, like diff(1), exits with status 1 if
+// there are differences and with status 2 on errors.
+type exitError struct {
+	status int
+	err    error
+}
+
+// Error returns the message of the wrapped error.
+func (e *exitError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+// register registers a subcommand.
+func register(name, usage string, run func([]string, io.Writer) error) {
+	commands[name] = &command{usage: usage, run: run}
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the subcommand given in args and returns the exit status.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "cdi: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	var exitErr *exitError
+
+	err := cmd.run(args[1:], stdout)
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		return 2
+	case errors.Is(err, errFailed):
+		return 1
+	case errors.As(err, &exitErr):
+		fmt.Fprintf(stderr, "cdi %s: %v\n", args[0], err)
+		return exitErr.status
+	default:
+		fmt.Fprintf(stderr, "cdi %s: %v\n", args[0], err)
+		return 1
+	}
+}
+
+// usage prints the list of subcommands.
+func usage(w io.Writer) {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "usage: cdi <command> [arguments]\n\ncommands:\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-10s %s\n", name, commands[name].usage)
+	}
+}
+
+// newFlagSet creates a flag set for a subcommand.
+func newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet("cdi "+name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: cdi %s [options] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseFlags parses the command line of a subcommand. Parsing errors
+// are reported by the flag set itself, together with its usage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	return nil
+}
+
+// writeFileAtomic replaces the given file with data, preserving the
+// permissions of an existing file.
+func writeFileAtomic(path string, data []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write %q: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSpec = `cdiVersion: "0.5.0"
+kind: vendor.com/device
+containerEdits:
+  env:
+    - VENDOR=1
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          hostPath: /dev/vendor0
+  - name: dev1
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor1
+`
+	testBadSpec = `cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env:
+        - NOT_AN_ASSIGNMENT
+`
+	testBadAnnotationSpec = `cdiVersion: "0.6.0"
+kind: vendor.com/device
+annotations:
+  "bad key!!": "1"
+  "__/__": "1"
+devices:
+  - name: dev0
+    containerEdits:
+      env:
+        - VENDOR=1
+`
+)
+
+// writeFiles writes the given files into a new temporary directory.
+func writeFiles(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+	return dir
+}
+
+// runCmd runs the cdi command line and returns its exit status and output.
+func runCmd(args ...string) (int, string, string) {
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	status := run(args, stdout, stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestUsage(t *testing.T) {
+	status, _, stderr := runCmd()
+	require.Equal(t, 2, status)
+	require.Contains(t, stderr, "validate")
+
+	status, _, stderr = runCmd("no-such-command")
+	require.Equal(t, 2, status)
+	require.Contains(t, stderr, "unknown command")
+}
+
+func TestValidate(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"good.yaml":        testSpec,
+		"bad.yaml":         testBadSpec,
+		"annotations.yaml": testBadAnnotationSpec,
+		"notes.txt":        "not a Spec",
+	})
+
+	status, stdout, _ := runCmd("validate", "--schema", "none", filepath.Join(dir, "good.yaml"))
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "OK    "+filepath.Join(dir, "good.yaml"))
+
+	status, stdout, _ = runCmd("validate", "--schema", "none", dir)
+	require.Equal(t, 1, status)
+	require.Contains(t, stdout, "FAIL  "+filepath.Join(dir, "bad.yaml"))
+	require.Contains(t, stdout, "NOT_AN_ASSIGNMENT")
+	require.Contains(t, stdout, "FAIL  "+filepath.Join(dir, "annotations.yaml"))
+	require.Contains(t, stdout, "OK    "+filepath.Join(dir, "good.yaml"))
+	require.NotContains(t, stdout, "notes.txt")
+
+	status, stdout, _ = runCmd("validate", "--schema", "none", filepath.Join(dir, "annotations.yaml"))
+	require.Equal(t, 1, status)
+	require.Contains(t, stdout, "spec: ")
+
+	status, _, _ = runCmd("validate")
+	require.Equal(t, 2, status)
+
+	status, _, stderr := runCmd("validate", "--schema", filepath.Join(dir, "missing.json"), dir)
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "failed to load JSON schema")
+}
+
+func TestList(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"vendor.yaml": testSpec,
+	})
+
+	status, stdout, _ := runCmd("list", "--spec-dir", dir)
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "vendor.com/device=dev0")
+	require.Contains(t, stdout, "vendor.com/device=dev1")
+	require.Contains(t, stdout, filepath.Join(dir, "vendor.yaml"))
+
+	status, stdout, _ = runCmd("list", "--spec-dir", dir, "-o", "json")
+	require.Equal(t, 0, status)
+	require.JSONEq(t, `{
+  "vendors": ["vendor.com"],
+  "classes": ["device"],
+  "devices": [
+    {"name": "vendor.com/device=dev0", "spec": "`+filepath.Join(dir, "vendor.yaml")+`", "priority": 0},
+    {"name": "vendor.com/device=dev1", "spec": "`+filepath.Join(dir, "vendor.yaml")+`", "priority": 0}
+  ]
+}`, stdout)
+
+	status, _, stderr := runCmd("list", "--spec-dir", dir, "-o", "xml")
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "invalid output format")
+}
+
+func TestShow(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"vendor.yaml": testSpec,
+	})
+
+	status, stdout, _ := runCmd("show", "--spec-dir", dir, "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "Device:    vendor.com/device=dev0")
+	require.Contains(t, stdout, "VENDOR=1")
+	require.Contains(t, stdout, "/dev/vendor0")
+
+	status, stdout, _ = runCmd("show", "--spec-dir", dir, "-o", "yaml", "vendor.com/device=dev1")
+	require.Equal(t, 0, status)
+	require.Equal(t, `containerEdits:
+  deviceNodes:
+  - path: /dev/vendor1
+  env:
+  - VENDOR=1
+deviceEdits:
+  deviceNodes:
+  - path: /dev/vendor1
+name: vendor.com/device=dev1
+priority: 0
+spec: `+filepath.Join(dir, "vendor.yaml")+`
+specEdits:
+  env:
+  - VENDOR=1
+`, stdout)
+
+	status, _, stderr := runCmd("show", "--spec-dir", dir, "vendor.com/device=dev2")
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "not found")
+
+	dropIn := filepath.Join(dir, "vendor.com-device.d", "10-local.yaml")
+	require.NoError(t, os.MkdirAll(filepath.Dir(dropIn), 0o755))
+	require.NoError(t, ioutil.WriteFile(dropIn, []byte(`patches:
+  - op: replace
+    containerEdits:
+      env: ["VENDOR=2"]
+  - op: add
+    device: dev0
+    containerEdits:
+      env: ["VENDOR_DEBUG=1"]
+`), 0o644))
+
+	status, stdout, _ = runCmd("show", "--spec-dir", dir, "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "Drop-in:   "+dropIn)
+	require.Contains(t, stdout, "VENDOR=2")
+	require.Contains(t, stdout, "VENDOR_DEBUG=1")
+	require.NotContains(t, stdout, "VENDOR=1")
+
+	dir = writeFiles(t, map[string]string{
+		"vendor.yaml": `cdiVersion: "0.7.0"
+kind: vendor.com/device
+devices:
+  - name: ctl
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendorctl
+          type: c
+          major: 195
+          minor: 255
+  - name: dev0
+    requires: ["ctl"]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+`,
+	})
+	status, stdout, _ = runCmd("show", "--spec-dir", dir, "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "Requires:  vendor.com/device=ctl")
+	require.Contains(t, stdout, "path: /dev/vendor0")
+	require.Contains(t, stdout, "path: /dev/vendorctl")
+
+	dir = writeFiles(t, map[string]string{
+		"vendor.yaml": `cdiVersion: "0.7.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    requires: ["other.com/device=missing"]
+    containerEdits:
+      env: ["VENDOR=1"]
+`,
+	})
+	status, stdout, _ = runCmd("show", "--spec-dir", dir, "vendor.com/device=dev0")
+	require.Equal(t, 1, status)
+	require.Contains(t, stdout, "Device:    vendor.com/device=dev0")
+	require.Contains(t, stdout, "Error:     failed to resolve edits")
+	require.NotContains(t, stdout, "Container edits:")
+
+	status, stdout, _ = runCmd("show", "--spec-dir", dir, "-o", "json", "vendor.com/device=dev0")
+	require.Equal(t, 1, status)
+	require.Contains(t, stdout, `"error": "failed to resolve edits: `)
+}
+
+func TestInject(t *testing.T) {
+	specDir := writeFiles(t, map[string]string{
+		"vendor.yaml": `cdiVersion: "0.5.0"
+kind: vendor.com/device
+containerEdits:
+  env:
+    - VENDOR=1
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+          minor: 0
+`,
+	})
+	config := `{"ociVersion": "1.1.0", "process": {"env": ["PATH=/bin"]}}`
+	bundle := writeFiles(t, map[string]string{
+		"config.json": config,
+	})
+	path := filepath.Join(bundle, "config.json")
+
+	status, stdout, _ := runCmd("inject", "--spec-dir", specDir, "--bundle", bundle, "--dry-run", "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "+\t\t\t\"VENDOR=1\"")
+	require.Contains(t, stdout, "+\t\t\t\t\"path\": \"/dev/vendor0\",")
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, config, string(data))
+
+	status, stdout, _ = runCmd("inject", "--spec-dir", specDir, "--bundle", bundle, "--stdout", "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "\"VENDOR=1\"")
+	data, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, config, string(data))
+
+	status, _, _ = runCmd("inject", "--spec-dir", specDir, "--bundle", bundle, "vendor.com/device=dev0")
+	require.Equal(t, 0, status)
+	data, err = ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Contains(t, string(data), "\"/dev/vendor0\"")
+	require.Contains(t, string(data), "\"VENDOR=1\"")
+
+	status, _, stderr := runCmd("inject", "--spec-dir", specDir, "--bundle", bundle, "vendor.com/device=dev1")
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "unresolvable")
+}
+
+func TestConvert(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"vendor.yaml": `cdiVersion: "0.6.0"
+kind: vendor.com/device
+devices:
+  - name: dev1
+    containerEdits:
+      env: ["B=1"]
+  - name: dev0
+    containerEdits:
+      env: ["A=1"]
+`,
+		"host-path.yaml": `cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          hostPath: /dev/vendor0
+`,
+	})
+
+	output := filepath.Join(dir, "vendor.json")
+	status, _, _ := runCmd("convert", "--version", "minimum", filepath.Join(dir, "vendor.yaml"), output)
+	require.Equal(t, 0, status)
+	data, err := ioutil.ReadFile(output)
+	require.NoError(t, err)
+	require.Equal(t, `{
+  "cdiVersion": "0.3.0",
+  "containerEdits": {},
+  "devices": [
+    {
+      "containerEdits": {
+        "env": [
+          "A=1"
+        ]
+      },
+      "name": "dev0"
+    },
+    {
+      "containerEdits": {
+        "env": [
+          "B=1"
+        ]
+      },
+      "name": "dev1"
+    }
+  ],
+  "kind": "vendor.com/device"
+}
+`, string(data))
+
+	status, stdout, _ := runCmd("convert", "--version", "latest", output)
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, "cdiVersion: 0.8.0\n")
+	require.Contains(t, stdout, "  name: dev0\n")
+
+	status, _, stderr := runCmd("convert", "--version", "0.4.0", filepath.Join(dir, "host-path.yaml"))
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "requires at least 0.5.0")
+}
+
+func TestDiff(t *testing.T) {
+	oldDir := writeFiles(t, map[string]string{
+		"vendor.yaml": testSpec,
+	})
+	newDir := writeFiles(t, map[string]string{
+		"vendor.json": `{"cdiVersion": "0.5.0", "kind": "vendor.com/device",
+  "containerEdits": {"env": ["VENDOR=2"]},
+  "devices": [
+    {"name": "dev1", "containerEdits": {"deviceNodes": [{"path": "/dev/vendor1"}]}},
+    {"name": "dev0", "containerEdits": {"deviceNodes": [{"path": "/dev/vendor0", "hostPath": "/dev/vendor0"}]}}
+  ]}`,
+	})
+
+	status, stdout, _ := runCmd("diff", oldDir, oldDir)
+	require.Equal(t, 0, status)
+	require.Equal(t, "", stdout)
+
+	status, stdout, _ = runCmd("diff", oldDir, newDir)
+	require.Equal(t, 1, status)
+	require.Equal(t, "~ vendor.com/device\n    ~ env VENDOR: VENDOR=1 -> VENDOR=2\n", stdout)
+
+	status, stdout, _ = runCmd("diff", "-o", "json", oldDir, t.TempDir())
+	require.Equal(t, 1, status)
+	require.JSONEq(t, `{"specs": [{"kind": "vendor.com/device", "status": "removed"}]}`, stdout)
+
+	status, _, stderr := runCmd("diff", oldDir, filepath.Join(oldDir, "missing.yaml"))
+	require.Equal(t, 2, status)
+	require.Contains(t, stderr, "no such file")
+
+	status, _, stderr = runCmd("diff", "-o", "xml", oldDir, newDir)
+	require.Equal(t, 2, status)
+	require.Contains(t, stderr, "xml")
+}
+
+func TestDoctor(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host device checks need Linux")
+	}
+
+	dir := writeFiles(t, map[string]string{
+		"vendor.yaml": `cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: devnull
+    containerEdits:
+      deviceNodes:
+        - path: /dev/null
+  - name: gone
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor-gone
+`,
+	})
+
+	status, stdout, _ := runCmd("doctor", "--spec-dir", dir)
+	require.Equal(t, 1, status)
+	require.Contains(t, stdout, "OK      vendor.com/device=devnull")
+	require.Contains(t, stdout, "BROKEN  vendor.com/device=gone")
+	require.Contains(t, stdout, `device node "/dev/vendor-gone"`)
+}
+
+func TestGenerate(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("host device nodes need Linux")
+	}
+
+	dir := t.TempDir()
+	status, stdout, _ := runCmd("generate", "--vendor", "acme.com", "--class", "fpga",
+		"--device-glob", "/dev/null", "--device-glob", "/dev/zero",
+		"--mount", "/usr/lib/libfpga.so", "--env", "FPGA_VISIBLE", "--env", "FPGA_DRIVER=1",
+		"--dir", dir)
+	require.Equal(t, 0, status)
+	path := filepath.Join(dir, "acme.com-fpga.yaml")
+	require.Equal(t, path+"\n", stdout)
+
+	data, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, `cdiVersion: 0.5.0
+containerEdits:
+  env:
+  - FPGA_DRIVER=1
+  mounts:
+  - containerPath: /usr/lib/libfpga.so
+    hostPath: /usr/lib/libfpga.so
+    options:
+    - ro
+    - nosuid
+    - nodev
+    - bind
+devices:
+- containerEdits:
+    deviceNodes:
+    - major: 1
+      minor: 3
+      path: /dev/null
+      type: c
+    env:
+    - FPGA_VISIBLE=0
+  name: "0"
+- containerEdits:
+    deviceNodes:
+    - major: 1
+      minor: 5
+      path: /dev/zero
+      type: c
+    env:
+    - FPGA_VISIBLE=1
+  name: "1"
+kind: acme.com/fpga
+`, string(data))
+
+	status, stdout, _ = runCmd("generate", "--vendor", "acme.com", "--class", "fpga",
+		"--device-glob", "/dev/null", "--name", "basename", "-o", "json", "--stdout")
+	require.Equal(t, 0, status)
+	require.Contains(t, stdout, `"cdiVersion": "0.3.0"`)
+	require.Contains(t, stdout, `"name": "null"`)
+
+	status, stdout, _ = runCmd("generate", "--vendor", "acme.com", "--class", "fpga",
+		"--device-glob", "/dev/nul?", "--device-glob", "/dev/null", "--device-glob", "/dev//zero",
+		"--device-glob", "/dev/zer[o]", "--name", "basename", "--stdout")
+	require.Equal(t, 0, status)
+	require.Equal(t, 1, strings.Count(stdout, "path: /dev/null"))
+	require.Equal(t, 1, strings.Count(stdout, "path: /dev/zero"))
+
+	status, _, stderr := runCmd("generate", "--vendor", "acme.com", "--class", "fpga",
+		"--device-glob", filepath.Join(dir, "fpga[0-9]*"))
+	require.Equal(t, 1, status)
+	require.Contains(t, stderr, "no host devices match")
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+// Output formats.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatYAML = "yaml"
+)
+
+// specDirsFlag is a repeatable flag for CDI Spec directories.
+type specDirsFlag []string
+
+// String returns the directories as a comma-separated list.
+func (f *specDirsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+// Set adds one or more comma-separated directories.
+func (f *specDirsFlag) Set(value string) error {
+	for _, dir := range strings.Split(value, ",") {
+		if dir != "" {
+			*f = append(*f, dir)
+		}
+	}
+	return nil
+}
+
+// registryFlags are the flags of commands working on the Spec directories.
+type registryFlags struct {
+	specDirs specDirsFlag
+	format   string
+}
+
+// add adds the registry flags to the given flag set.
+func (r *registryFlags) add(fs *flag.FlagSet) {
+	r.addSpecDirs(fs)
+	fs.StringVar(&r.format, "o", formatText, "output format, 'text', 'json' or 'yaml'")
+}
+
+// addSpecDirs adds only the Spec directory flag to the given flag set.
+func (r *registryFlags) addSpecDirs(fs *flag.FlagSet) {
+	fs.Var(&r.specDirs, "spec-dir",
+		"CDI Spec directory, in increasing order of priority (repeatable, default "+
+			strings.Join(cdi.DefaultSpecDirs, ",")+")")
+}
+
+// validate checks the registry flags.
+func (r *registryFlags) validate() error {
+	switch r.format {
+	case formatText, formatJSON, formatYAML:
+		return nil
+	}
+	return fmt.Errorf("invalid output format %q", r.format)
+}
+
+// newCache creates a Cache for the Spec directories given by the flags.
+func (r *registryFlags) newCache() (*cdi.Cache, error) {
+	dirs := []string(r.specDirs)
+	if len(dirs) == 0 {
+		dirs = cdi.DefaultSpecDirs
+	}
+	return cdi.NewCache(cdi.WithSpecDirs(dirs...))
+}
+
+// writeOutput writes v in the requested format, using text for the text
+// format.
+func writeOutput(w io.Writer, format string, v interface{}, text func(io.Writer) error) error {
+	switch format {
+	case formatJSON:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case formatYAML:
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	}
+	return text(w)
+}
+
+// errorStrings converts errors per path to strings per path.
+func errorStrings(errors map[string][]error) map[string][]string {
+	if len(errors) == 0 {
+		return nil
+	}
+	result := map[string][]string{}
+	for path, errs := range errors {
+		for _, err := range errs {
+			result[path] = append(result[path], err.Error())
+		}
+	}
+	return result
+}
+
+// writeErrors writes errors per path as text, sorted by path.
+func writeErrors(w io.Writer, errors map[string][]string) {
+	if len(errors) == 0 {
+		return
+	}
+
+	var paths []string
+	for path := range errors {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fmt.Fprintln(w, "Errors:")
+	for _, path := range paths {
+		for _, err := range errors[path] {
+			fmt.Fprintf(w, "  %s: %s\n", path, err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"container-device-interface-aaron/pkg/cdi"
+	specs "container-device-interface-aaron/specs-go"
+)
+
+func init() {
+	register("show", "show the resolved edits of a CDI device", showCmd)
+}
+
+// showOutput is the output of the show command.
+type showOutput struct {
+	Name     string `json:"name"`
+	Spec     string `json:"spec"`
+	Priority int    `json:"priority"`
+	// DropIns are the drop-ins applied to the Spec, in order.
+	DropIns []string `json:"dropIns,omitempty"`
+	// AliasOf is the device aliased by an alias device.
+	AliasOf string `json:"aliasOf,omitempty"`
+	// Members are the member devices of a composite device.
+	Members []string `json:"members,omitempty"`
+	// Requires are the qualified devices injected along with the device.
+	Requires []string `json:"requires,omitempty"`
+	// Conflicts are the qualified devices never injected with the device.
+	Conflicts []string `json:"conflicts,omitempty"`
+	// Annotations are the Spec and device annotations, the latter
+	// taking precedence.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// SpecEdits are the Spec-level edits, applied for any device of the Spec.
+	SpecEdits specs.ContainerEdits `json:"specEdits"`
+	// DeviceEdits are the device-specific edits, resolved for alias and
+	// composite devices.
+	DeviceEdits specs.ContainerEdits `json:"deviceEdits"`
+	// ContainerEdits are the resolved edits injected for the device,
+	// including the edits of the devices it requires.
+	ContainerEdits specs.ContainerEdits `json:"containerEdits"`
+	// Error is the error resolving the injected edits, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// showCmd shows the origin, annotations and resolved container edits
+// of a single qualified device. If the edits can't be resolved, the
+// device is shown with the error and the command fails.
+func showCmd(args []string, stdout io.Writer) error {
+	var flags registryFlags
+
+	fs := newFlagSet("show", "<vendor/class=name>")
+	flags.add(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	if err := flags.validate(); err != nil {
+		return err
+	}
+
+	cache, err := flags.newCache()
+	if err != nil {
+		return err
+	}
+
+	name := fs.Arg(0)
+	dev := cache.GetDevice(name)
+	if dev == nil {
+		return fmt.Errorf("device %q not found", name)
+	}
+
+	out := newShowOutput(cache, dev)
+	if err := writeOutput(stdout, flags.format, out, out.writeText); err != nil {
+		return err
+	}
+	if out.Error != "" {
+		return errFailed
+	}
+	return nil
+}
+
+// newShowOutput collects the show output for a device.
+func newShowOutput(cache *cdi.Cache, dev *cdi.Device) *showOutput {
+	spec := dev.GetSpec()
+	out := &showOutput{
+		Name:        dev.GetQualifiedName(),
+		Spec:        spec.GetPath(),
+		Priority:    spec.GetPriority(),
+		DropIns:     spec.GetDropIns(),
+		AliasOf:     dev.AliasOf,
+		Members:     dev.Members,
+		Requires:    dev.GetRequires(),
+		Conflicts:   dev.GetConflicts(),
+		SpecEdits:   spec.ContainerEdits,
+		DeviceEdits: *dev.GetContainerEdits().ContainerEdits,
+	}
+
+	for k, v := range spec.Annotations {
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[k] = v
+	}
+	for k, v := range dev.Annotations {
+		if out.Annotations == nil {
+			out.Annotations = map[string]string{}
+		}
+		out.Annotations[k] = v
+	}
+
+	edits, _, err := cache.GetInjectedEdits(out.Name)
+	if err != nil {
+		out.Error = fmt.Sprintf("failed to resolve edits: %v", err)
+		return out
+	}
+	out.ContainerEdits = *edits.ContainerEdits
+
+	return out
+}
+
+// writeText writes the device details in human-readable form.
+func (o *showOutput) writeText(w io.Writer) error {
+	fmt.Fprintf(w, "Device:    %s\n", o.Name)
+	fmt.Fprintf(w, "Spec:      %s\n", o.Spec)
+	fmt.Fprintf(w, "Priority:  %d\n", o.Priority)
+	for _, path := range o.DropIns {
+		fmt.Fprintf(w, "Drop-in:   %s\n", path)
+	}
+	if o.AliasOf != "" {
+		fmt.Fprintf(w, "Alias of:  %s\n", o.AliasOf)
+	}
+	if len(o.Members) > 0 {
+		fmt.Fprintf(w, "Members:   %s\n", strings.Join(o.Members, ", "))
+	}
+	if len(o.Requires) > 0 {
+		fmt.Fprintf(w, "Requires:  %s\n", strings.Join(o.Requires, ", "))
+	}
+	if len(o.Conflicts) > 0 {
+		fmt.Fprintf(w, "Conflicts: %s\n", strings.Join(o.Conflicts, ", "))
+	}
+
+	if len(o.Annotations) > 0 {
+		var keys []string
+		for k := range o.Annotations {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		fmt.Fprintln(w, "Annotations:")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s: %s\n", k, o.Annotations[k])
+		}
+	}
+
+	if o.Error != "" {
+		fmt.Fprintf(w, "Error:     %s\n", o.Error)
+		return nil
+	}
+
+	data, err := yaml.Marshal(o.ContainerEdits)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(w, "Container edits:")
+	_, err = w.Write(indent(data, "  "))
+	return err
+}
+
+// indent prefixes each non-empty line of data.
+func indent(data []byte, prefix string) []byte {
+	var out []byte
+	start := true
+	for _, b := range data {
+		if start && b != '\n' {
+			out = append(out, prefix...)
+		}
+		out = append(out, b)
+		start = b == '\n'
+	}
+	return out
+}
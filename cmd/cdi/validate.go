@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"container-device-interface-aaron/pkg/cdi"
+	"container-device-interface-aaron/schema"
+)
+
+func init() {
+	register("validate", "validate CDI Spec files", validateCmd)
+}
+
+// validateCmd validates the given Spec files and directories against the
+// JSON schema and the semantic rules of the CDI Spec.
+func validateCmd(args []string, stdout io.Writer) error {
+	fs := newFlagSet("validate", "<file|dir>...")
+	schemaName := fs.String("schema", schema.BuiltinSchemaName,
+		"JSON schema to validate against, 'builtin', 'none' or a path or URL")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	scm, err := schema.Load(*schemaName)
+	if err != nil {
+		return err
+	}
+
+	files, err := collectSpecFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	failed := false
+	for _, path := range files {
+		errs := validateSpecFile(scm, path)
+		if len(errs) == 0 {
+			fmt.Fprintf(stdout, "OK    %s\n", path)
+			continue
+		}
+		failed = true
+		fmt.Fprintf(stdout, "FAIL  %s\n", path)
+		for _, err := range errs {
+			fmt.Fprintf(stdout, "      %v\n", err)
+		}
+	}
+
+	if failed {
+		return errFailed
+	}
+	return nil
+}
+
+// validateSpecFile validates a single Spec file, returning all errors.
+func validateSpecFile(scm *schema.Schema, path string) []error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, err := range scm.ValidateStream(data) {
+		errs = append(errs, fmt.Errorf("schema: %w", err))
+	}
+	specs, specErrs := cdi.ReadSpecs(path, 0)
+	for _, err := range specErrs {
+		errs = append(errs, fmt.Errorf("spec: %w", err))
+	}
+	if len(specs) == 0 && len(specErrs) == 0 {
+		errs = append(errs, fmt.Errorf("spec: no Spec data"))
+	}
+
+	return errs
+}
+
+// collectSpecFiles expands the given files and directories into a sorted
+// list of Spec files. Directories are scanned for '.json' and '.yaml'
+// files, without descending into subdirectories. Files given explicitly
+// are always included.
+func collectSpecFiles(paths []string) ([]string, error) {
+	var files []string
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var found []string
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if ext := filepath.Ext(e.Name()); ext != ".json" && ext != ".yaml" {
+				continue
+			}
+			found = append(found, filepath.Join(path, e.Name()))
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+
+	return files, nil
+}
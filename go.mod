@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/opencontainers/runtime-spec v1.1.0
 	github.com/stretchr/testify v1.8.4
+	golang.org/x/sys v0.1.0
 )
 
 require (
@@ -16,8 +17,8 @@ require (
 require (
 	github.com/container-orchestrated-devices/container-device-interface v0.6.0
 	github.com/davecgh/go-spew v1.1.1 // indirect
-	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0
 	github.com/xeipuuv/gojsonschema v1.2.0
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1
 	sigs.k8s.io/yaml v1.3.0
 )
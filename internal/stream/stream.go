@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Document is a single document of a multi-document YAML or JSON stream.
+type Document struct {
+	// Index is the position of the document among the non-empty
+	// documents of the stream, starting from 0.
+	Index int
+	// Line is the line number the document starts at, starting from 1.
+	Line int
+	// Data is the raw content of the document.
+	Data []byte
+}
+
+// Error is an error for a single document of a stream.
+type Error struct {
+	Index int
+	Line  int
+	Err   error
+}
+
+// Error returns the error string, prefixed with the document position.
+func (e *Error) Error() string {
+	return fmt.Sprintf("document #%d (line %d): %v", e.Index, e.Line, e.Err)
+}
+
+// Unwrap returns the wrapped error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Wrap wraps err into an Error for the document.
+func (d *Document) Wrap(err error) *Error {
+	return &Error{Index: d.Index, Line: d.Line, Err: err}
+}
+
+// IsJSON returns true if the document looks like JSON data.
+func (d *Document) IsJSON() bool {
+	return IsJSON(d.Data)
+}
+
+// Split splits data into its documents. Data which is entirely a stream
+// of JSON objects, such as JSON Lines, is split into those objects.
+// Otherwise data is treated as a YAML stream with documents separated
+// by "---", which also covers YAML flow style documents starting with
+// '{'. Empty documents are omitted.
+func Split(data []byte) []*Document {
+	if isJSONStream(data) {
+		return splitJSON(data)
+	}
+	return splitYAML(data)
+}
+
+// splitJSON splits a valid stream of JSON values.
+func splitJSON(data []byte) []*Document {
+	var (
+		docs []*Document
+		dec  = json.NewDecoder(bytes.NewReader(data))
+		prev int64
+	)
+
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			break
+		}
+		start := prev + int64(leadingSpace(data[prev:]))
+		prev = dec.InputOffset()
+		docs = append(docs, &Document{
+			Index: len(docs),
+			Line:  lineOf(data, start),
+			Data:  []byte(raw),
+		})
+	}
+
+	return docs
+}
+
+// splitYAML splits a YAML stream on "---" separator lines.
+func splitYAML(data []byte) []*Document {
+	var (
+		docs  []*Document
+		cur   []byte
+		start = 1
+	)
+
+	flush := func() {
+		if !isEmptyYAML(cur) {
+			docs = append(docs, &Document{
+				Index: len(docs),
+				Line:  start,
+				Data:  cur,
+			})
+		}
+		cur = nil
+	}
+
+	for i, line := range bytes.SplitAfter(data, []byte{'\n'}) {
+		if isSeparator(line) {
+			flush()
+			start = i + 2
+			continue
+		}
+		cur = append(cur, line...)
+	}
+	flush()
+
+	return docs
+}
+
+// isSeparator returns true if line is a YAML document separator.
+func isSeparator(line []byte) bool {
+	return string(bytes.TrimRight(line, " \t\r\n")) == "---"
+}
+
+// isEmptyYAML returns true if data only has whitespace and comments.
+func isEmptyYAML(data []byte) bool {
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 && line[0] != '#' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsJSON returns true if data starts like a JSON object. Such data may
+// still be a YAML flow style document.
+func IsJSON(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte{'{'})
+}
+
+// isJSONStream returns true if data is a stream of valid JSON objects.
+func isJSONStream(data []byte) bool {
+	if !IsJSON(data) {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var raw json.RawMessage
+		err := dec.Decode(&raw)
+		if err == io.EOF {
+			return true
+		}
+		if err != nil || !IsJSON(raw) {
+			return false
+		}
+	}
+}
+
+// leadingSpace returns the number of leading whitespace bytes in data.
+func leadingSpace(data []byte) int {
+	return len(data) - len(bytes.TrimLeft(data, " \t\r\n"))
+}
+
+// lineOf returns the line number of the given offset in data.
+func lineOf(data []byte, offset int64) int {
+	return bytes.Count(data[:offset], []byte{'\n'}) + 1
+}
@@ -0,0 +1,88 @@
+package stream
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplit(t *testing.T) {
+	type document struct {
+		line int
+		data string
+	}
+	testCases := []struct {
+		name      string
+		data      string
+		documents []document
+	}{
+		{
+			name: "empty data",
+			data: "",
+		},
+		{
+			name: "single YAML document",
+			data: "cdiVersion: 0.3.0\nkind: vendor.com/device\n",
+			documents: []document{
+				{line: 1, data: "cdiVersion: 0.3.0\nkind: vendor.com/device\n"},
+			},
+		},
+		{
+			name: "multiple YAML documents",
+			data: "---\nkind: vendor.com/a\n---\n# comment only\n---\nkind: vendor.com/b\n",
+			documents: []document{
+				{line: 2, data: "kind: vendor.com/a\n"},
+				{line: 6, data: "kind: vendor.com/b\n"},
+			},
+		},
+		{
+			name: "single multi-line JSON document",
+			data: "{\n  \"kind\": \"vendor.com/a\"\n}\n",
+			documents: []document{
+				{line: 1, data: "{\n  \"kind\": \"vendor.com/a\"\n}"},
+			},
+		},
+		{
+			name: "JSON Lines",
+			data: "{\"kind\": \"vendor.com/a\"}\n\n{\"kind\": \"vendor.com/b\"}\n",
+			documents: []document{
+				{line: 1, data: "{\"kind\": \"vendor.com/a\"}"},
+				{line: 3, data: "{\"kind\": \"vendor.com/b\"}"},
+			},
+		},
+		{
+			name: "concatenated JSON",
+			data: "{\n\"kind\": \"vendor.com/a\"\n}\n{\n\"kind\": \"vendor.com/b\"\n}\n",
+			documents: []document{
+				{line: 1, data: "{\n\"kind\": \"vendor.com/a\"\n}"},
+				{line: 4, data: "{\n\"kind\": \"vendor.com/b\"\n}"},
+			},
+		},
+		{
+			name: "YAML flow style document",
+			data: "{kind: vendor.com/a, devices: [{name: dev0}]}\n",
+			documents: []document{
+				{line: 1, data: "{kind: vendor.com/a, devices: [{name: dev0}]}\n"},
+			},
+		},
+		{
+			name: "invalid JSON is a single YAML document",
+			data: "{\"kind\": \"vendor.com/a\"}\n{\"kind\": }\n",
+			documents: []document{
+				{line: 1, data: "{\"kind\": \"vendor.com/a\"}\n{\"kind\": }\n"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			docs := Split([]byte(tc.data))
+			require.Len(t, docs, len(tc.documents))
+			for i, doc := range docs {
+				require.Equal(t, i, doc.Index)
+				require.Equal(t, tc.documents[i].line, doc.Line)
+				require.Equal(t, tc.documents[i].data, string(doc.Data))
+			}
+		})
+	}
+}
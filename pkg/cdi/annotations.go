@@ -0,0 +1,122 @@
+package cdi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+)
+
+const (
+	// AnnotationPrefix is the prefix of CDI container annotation keys.
+	AnnotationPrefix = "cdi.k8s.io/"
+	// maxAnnotationNameLen is the maximum length of the name part of a
+	// Kubernetes annotation key.
+	maxAnnotationNameLen = 63
+)
+
+// UpdateAnnotations updates annotations with a plugin-specific CDI device
+// injection request for the given devices. On any error annotations are
+// left intact and an error is returned. By convention plugin should be
+// in the format "vendor.device-type".
+func UpdateAnnotations(annotations map[string]string, plugin string, deviceID string, devices []string) (map[string]string, error) {
+	key, err := AnnotationKey(plugin, deviceID)
+	if err != nil {
+		return annotations, fmt.Errorf("CDI annotation failed: %w", err)
+	}
+	if _, ok := annotations[key]; ok {
+		return annotations, fmt.Errorf("CDI annotation failed, key %q used", key)
+	}
+	value, err := AnnotationValue(devices)
+	if err != nil {
+		return annotations, fmt.Errorf("CDI annotation failed: %w", err)
+	}
+
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[key] = value
+
+	return annotations, nil
+}
+
+// ParseAnnotations parses annotations for CDI device injection requests.
+// It returns the sorted keys of all such requests and the requested
+// devices in key order. All devices must be qualified CDI device names,
+// otherwise ParseAnnotations returns an error.
+func ParseAnnotations(annotations map[string]string) ([]string, []string, error) {
+	var (
+		keys    []string
+		devices []string
+	)
+
+	for key := range annotations {
+		if strings.HasPrefix(key, AnnotationPrefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, d := range strings.Split(annotations[key], ",") {
+			if !parser.IsQualifiedName(d) {
+				return nil, nil, fmt.Errorf("invalid CDI device name %q in annotation %q", d, key)
+			}
+			devices = append(devices, d)
+		}
+	}
+
+	return keys, devices, nil
+}
+
+// AnnotationKey returns the annotation key for a device allocation by a
+// Kubernetes device plugin. plugin should be in the format "vendor.device-type".
+// deviceID is the ID of the allocated device, it makes the key unique if
+// a single plugin annotates multiple allocations. The name part of the
+// key follows Kubernetes annotation rules: at most 63 characters, only
+// alphanumerics, '-', '_' and '.', starting and ending with an alphanumeric.
+func AnnotationKey(plugin, deviceID string) (string, error) {
+	if plugin == "" {
+		return "", errors.New("invalid plugin name, empty")
+	}
+	if deviceID == "" {
+		return "", errors.New("invalid deviceID, empty")
+	}
+
+	name := plugin + "_" + strings.ReplaceAll(deviceID, "/", "_")
+
+	if len(name) > maxAnnotationNameLen {
+		return "", fmt.Errorf("invalid plugin+deviceID %q, too long", name)
+	}
+	if c := rune(name[0]); !parser.IsAlphaNumeric(c) {
+		return "", fmt.Errorf("invalid name %q, first '%c' should be alphanumeric", name, c)
+	}
+	for _, c := range name[1 : len(name)-1] {
+		switch {
+		case parser.IsAlphaNumeric(c):
+		case c == '_' || c == '-' || c == '.':
+		default:
+			return "", fmt.Errorf("invalid name %q, invalid character '%c'", name, c)
+		}
+	}
+	if c := rune(name[len(name)-1]); !parser.IsAlphaNumeric(c) {
+		return "", fmt.Errorf("invalid name %q, last '%c' should be alphanumeric", name, c)
+	}
+
+	return AnnotationPrefix + name, nil
+}
+
+// AnnotationValue returns the annotation value for the given devices.
+func AnnotationValue(devices []string) (string, error) {
+	if len(devices) == 0 {
+		return "", errors.New("invalid devices, empty")
+	}
+	for _, d := range devices {
+		if _, _, _, err := parser.ParseQualifiedName(d); err != nil {
+			return "", err
+		}
+	}
+	return strings.Join(devices, ","), nil
+}
@@ -0,0 +1,122 @@
+package cdi
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnnotations(t *testing.T) {
+	type request struct {
+		plugin   string
+		deviceID string
+		devices  []string
+	}
+	testCases := []struct {
+		name        string
+		requests    []request
+		invalid     bool
+		keys        []string
+		devices     []string
+		annotations map[string]string
+	}{
+		{
+			name: "single request",
+			requests: []request{
+				{"vendor.gpu", "0", []string{"vendor.com/gpu=0"}},
+			},
+			keys:    []string{"cdi.k8s.io/vendor.gpu_0"},
+			devices: []string{"vendor.com/gpu=0"},
+		},
+		{
+			name: "invalid character in deviceID",
+			requests: []request{
+				{"vendor.gpu", "pci/0000:01:00.0", []string{"vendor.com/gpu=0", "vendor.com/gpu=1"}},
+				{"other.fpga", "fpga0", []string{"other.com/fpga=fpga0"}},
+			},
+			invalid: true,
+		},
+		{
+			name: "multiple valid requests",
+			requests: []request{
+				{"vendor.gpu", "pci_0000-01-00.0", []string{"vendor.com/gpu=0", "vendor.com/gpu=1"}},
+				{"other.fpga", "fpga0", []string{"other.com/fpga=fpga0"}},
+			},
+			keys: []string{
+				"cdi.k8s.io/other.fpga_fpga0",
+				"cdi.k8s.io/vendor.gpu_pci_0000-01-00.0",
+			},
+			devices: []string{"other.com/fpga=fpga0", "vendor.com/gpu=0", "vendor.com/gpu=1"},
+		},
+		{
+			name: "duplicate key",
+			requests: []request{
+				{"vendor.gpu", "0", []string{"vendor.com/gpu=0"}},
+				{"vendor.gpu", "0", []string{"vendor.com/gpu=1"}},
+			},
+			invalid: true,
+		},
+		{
+			name: "too long key",
+			requests: []request{
+				{"vendor.gpu", strings.Repeat("0", 53), []string{"vendor.com/gpu=0"}},
+			},
+			invalid: true,
+		},
+		{
+			name: "invalid key end",
+			requests: []request{
+				{"vendor.gpu", "0-", []string{"vendor.com/gpu=0"}},
+			},
+			invalid: true,
+		},
+		{
+			name: "unqualified device",
+			requests: []request{
+				{"vendor.gpu", "0", []string{"gpu0"}},
+			},
+			invalid: true,
+		},
+		{
+			name: "invalid device in foreign annotation",
+			annotations: map[string]string{
+				"cdi.k8s.io/manual": "vendor.com/gpu=0,gpu1",
+			},
+			invalid: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			annotations := map[string]string{"unrelated": "value"}
+			for k, v := range tc.annotations {
+				annotations[k] = v
+			}
+
+			var err error
+			for _, r := range tc.requests {
+				before := len(annotations)
+				annotations, err = UpdateAnnotations(annotations, r.plugin, r.deviceID, r.devices)
+				if err != nil {
+					require.Len(t, annotations, before)
+					break
+				}
+			}
+			if err == nil {
+				var keys, devices []string
+				keys, devices, err = ParseAnnotations(annotations)
+				if err == nil {
+					require.Equal(t, tc.keys, keys)
+					require.Equal(t, tc.devices, devices)
+				}
+			}
+
+			if tc.invalid {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,627 @@
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/internal/multierror"
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+// Option is an option to change some aspect of default CDI behavior.
+type Option func(*Cache) error
+
+// Cache stores CDI Specs loaded from Spec directories.
+type Cache struct {
+	sync.Mutex
+	specDirs []string
+	specs    map[string][]*Spec
+	devices  map[string]*Device
+	errors   map[string][]error
+	warnings map[string][]error
+
+	dirPriorities []int
+
+	strict    StrictMode
+	signature SignatureMode
+	trust     *TrustStore
+
+	conditions ConditionEvaluator
+	validator  func(*cdi.Spec) error
+	policies   []*Policy
+
+	secure      bool
+	trustedUIDs []uint32
+
+	refreshInterval time.Duration
+	refreshed       time.Time
+}
+
+// NewCache creates a new CDI Cache. The cache is populated from a set
+// of CDI Spec directories. These can be specified using a WithSpecDirs
+// option. The default set of directories is exposed in DefaultSpecDirs.
+func NewCache(options ...Option) (*Cache, error) {
+	c := &Cache{}
+
+	WithSpecDirs(DefaultSpecDirs...)(c)
+	c.Lock()
+	defer c.Unlock()
+
+	return c, c.configure(options...)
+}
+
+// WithAutoRefresh returns an option to refresh the Cache automatically
+// when it is accessed and was last refreshed at least interval ago. An
+// interval of 0 disables automatic refresh, which is the default.
+func WithAutoRefresh(interval time.Duration) Option {
+	return func(c *Cache) error {
+		if interval < 0 {
+			return fmt.Errorf("invalid refresh interval %s", interval)
+		}
+		c.refreshInterval = interval
+		return nil
+	}
+}
+
+// WithSpecValidator returns an option to validate every loaded Spec,
+// after applying its drop-ins, with the given function. Specs failing
+// validation are not loaded.
+func WithSpecValidator(fn func(*cdi.Spec) error) Option {
+	return func(c *Cache) error {
+		c.validator = fn
+		return nil
+	}
+}
+
+// Configure applies options to the Cache. Updates and refreshes the
+// Cache if options have changed.
+func (c *Cache) Configure(options ...Option) error {
+	if len(options) == 0 {
+		return nil
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	return c.configure(options...)
+}
+
+// Configure the Cache and refresh it.
+func (c *Cache) configure(options ...Option) error {
+	for _, o := range options {
+		if err := o(c); err != nil {
+			return fmt.Errorf("failed to apply cache options: %w", err)
+		}
+	}
+
+	c.refresh()
+
+	return nil
+}
+
+// Refresh rescans the CDI Spec directories and refreshes the Cache.
+func (c *Cache) Refresh() error {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.refresh()
+}
+
+// refreshIfRequired refreshes the Cache if automatic refresh is enabled
+// and the last refresh is too old.
+func (c *Cache) refreshIfRequired() {
+	if c.refreshInterval > 0 && time.Since(c.refreshed) >= c.refreshInterval {
+		_ = c.refresh()
+	}
+}
+
+// Refresh the Cache by rescanning CDI Spec directories and files.
+func (c *Cache) refresh() error {
+	c.refreshed = time.Now()
+
+	var (
+		specs      = map[string][]*Spec{}
+		devices    = map[string]*Device{}
+		conflicts  = map[string]struct{}{}
+		specErrors = map[string][]error{}
+		warnings   = map[string][]error{}
+		result     []error
+	)
+
+	// collect errors per spec file path and once globally
+	collectError := func(err error, paths ...string) {
+		result = append(result, err)
+		for _, path := range paths {
+			specErrors[path] = append(specErrors[path], err)
+		}
+	}
+	// resolve conflicts based on device Spec priority (order of precedence)
+	resolveConflict := func(name string, dev *Device, old *Device) bool {
+		devSpec, oldSpec := dev.GetSpec(), old.GetSpec()
+		devPrio, oldPrio := devSpec.GetPriority(), oldSpec.GetPriority()
+		switch {
+		case devPrio > oldPrio:
+			return false
+		case devPrio == oldPrio:
+			devPath, oldPath := devSpec.GetPath(), oldSpec.GetPath()
+			collectError(fmt.Errorf("conflicting device %q (specs %q, %q)",
+				name, devPath, oldPath), devPath, oldPath)
+			conflicts[name] = struct{}{}
+		}
+		return true
+	}
+
+	// read each drop-in once and record its errors once, check device
+	// patches against the devices of all Specs of the drop-in vendor and
+	// class once all are loaded
+	var (
+		dropIns       = map[string]*DropIn{}
+		dropInKinds   = map[string]string{}
+		failedDropIns = map[string]bool{}
+		kindDevices   = map[string]map[string]bool{}
+	)
+	readDropIn := func(path, kind string) *DropIn {
+		if dropIn, ok := dropIns[path]; ok {
+			return dropIn
+		}
+		// drop-ins are not signed, so they must not patch verified Specs
+		if c.signature != SignatureModeDisabled {
+			collectError(fmt.Errorf("CDI Spec drop-in %q: can't patch Specs with signature verification enabled",
+				path), path)
+			dropIns[path], dropInKinds[path] = nil, kind
+			return nil
+		}
+		var (
+			dropIn *DropIn
+			err    error
+		)
+		if c.secure {
+			dropIn, err = readSecureDropIn(path, c.trustedUIDs)
+		} else {
+			dropIn, err = ReadDropIn(path)
+		}
+		if err != nil {
+			collectError(err, path)
+		}
+		dropIns[path], dropInKinds[path] = dropIn, kind
+		return dropIn
+	}
+	dropInFailed := func(dropIn *DropIn, err error) {
+		if !failedDropIns[dropIn.path] {
+			failedDropIns[dropIn.path] = true
+			collectError(err, dropIn.path)
+		}
+	}
+
+	// with secure loading, skip insecure Spec directories and files
+	insecureDirs := map[string]bool{}
+	if c.secure {
+		for _, dir := range c.specDirs {
+			if _, err := os.Stat(dir); err != nil {
+				continue
+			}
+			if err := CheckSpecFileSecurity(dir, dir, c.trustedUIDs); err != nil {
+				collectError(err, dir)
+				insecureDirs[dir] = true
+			}
+		}
+	}
+
+	dirsByPriority := map[int]string{}
+	for i, dir := range c.specDirs {
+		dirsByPriority[specDirPriority(c.dirPriorities, i)] = dir
+	}
+
+	_ = scanSpecDirs(c.specDirs, c.dirPriorities, func(path string, priority int) error {
+		path = filepath.Clean(path)
+
+		var (
+			loaded      []*Spec
+			errs, warns []error
+		)
+		if c.secure {
+			dir := dirsByPriority[priority]
+			if insecureDirs[dir] {
+				return nil
+			}
+			loaded, errs, warns = readSecureSpecs(dir, path, priority, c.strict, c.trustedUIDs)
+		} else {
+			loaded, errs, warns = readSpecs(path, priority, c.strict)
+		}
+		for _, err := range errs {
+			collectError(err, path)
+		}
+		if len(warns) > 0 {
+			warnings[path] = append(warnings[path], warns...)
+		}
+
+		for _, spec := range loaded {
+			if c.signature != SignatureModeDisabled {
+				if err := c.trust.Verify(spec); err != nil {
+					err = fmt.Errorf("CDI Spec %q: %w", path, err)
+					if c.signature == SignatureModeError {
+						collectError(err, path)
+						continue
+					}
+					warnings[path] = append(warnings[path], err)
+				}
+			}
+
+			if kindDevices[spec.Kind] == nil {
+				kindDevices[spec.Kind] = map[string]bool{}
+			}
+			for _, d := range spec.Devices {
+				kindDevices[spec.Kind][d.Name] = true
+			}
+
+			var specDropIns []*DropIn
+			for _, path := range findDropIns(c.specDirs, spec.GetVendor(), spec.GetClass()) {
+				if d := readDropIn(path, spec.Kind); d != nil {
+					specDropIns = append(specDropIns, d)
+				}
+			}
+			if len(specDropIns) > 0 {
+				spec = applyDropIns(spec, specDropIns, dropInFailed)
+			}
+
+			if c.validator != nil {
+				if err := c.validator(spec.Spec); err != nil {
+					collectError(fmt.Errorf("invalid CDI Spec %q: %w", path, err), path)
+					continue
+				}
+			}
+			if err := checkPolicies(c.policies, spec); err != nil {
+				collectError(fmt.Errorf("CDI Spec %q: %w", path, err), path)
+				continue
+			}
+
+			vendor := spec.GetVendor()
+			specs[vendor] = append(specs[vendor], spec)
+
+			for _, dev := range spec.devices {
+				qualified := dev.GetQualifiedName()
+				other, ok := devices[qualified]
+				if ok {
+					if resolveConflict(qualified, dev, other) {
+						continue
+					}
+				}
+				devices[qualified] = dev
+			}
+		}
+
+		return nil
+	})
+
+	for conflict := range conflicts {
+		delete(devices, conflict)
+	}
+
+	dropInPaths := make([]string, 0, len(dropIns))
+	for path, dropIn := range dropIns {
+		if dropIn != nil {
+			dropInPaths = append(dropInPaths, path)
+		}
+	}
+	sort.Strings(dropInPaths)
+	for _, path := range dropInPaths {
+		if err := dropIns[path].checkDevices(kindDevices[dropInKinds[path]]); err != nil {
+			collectError(err, path)
+		}
+	}
+
+	c.specs = specs
+	c.devices = devices
+	c.errors = specErrors
+	c.warnings = warnings
+
+	return multierror.New(result...)
+}
+
+// InjectDevices injects the given qualified devices to an OCI Spec. It
+// returns any unresolvable devices and an error if injection fails for
+// any of the devices.
+func (c *Cache) InjectDevices(ociSpec *oci.Spec, devices ...string) ([]string, error) {
+	if ociSpec == nil {
+		return devices, fmt.Errorf("can't inject devices, nil OCI Spec")
+	}
+
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	return c.injectDevices(ociSpec, devices, nil)
+}
+
+// GetInjectedEdits returns the container edits InjectDevices injects for
+// the given qualified devices, including the Spec edits, the edits of
+// required devices and the matching conditional edits. It returns any
+// unresolvable devices and an error if injection would fail for any of
+// the devices.
+func (c *Cache) GetInjectedEdits(devices ...string) (*ContainerEdits, []string, error) {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	return c.injectedEdits(devices, nil)
+}
+
+// injectDevices injects the given qualified devices to an OCI Spec,
+// overriding the device node permissions of the devices in perms.
+func (c *Cache) injectDevices(ociSpec *oci.Spec, devices []string, perms map[string]string) ([]string, error) {
+	edits, unresolved, err := c.injectedEdits(devices, perms)
+	if err != nil {
+		return unresolved, err
+	}
+	if err := edits.Apply(ociSpec); err != nil {
+		return nil, fmt.Errorf("failed to inject devices: %w", err)
+	}
+
+	return nil, nil
+}
+
+// injectedEdits returns the container edits to inject for the given
+// qualified devices, overriding the device node permissions of the
+// devices in perms.
+func (c *Cache) injectedEdits(devices []string, perms map[string]string) (*ContainerEdits, []string, error) {
+	var unresolved []string
+
+	var (
+		specs    []*Spec
+		seen     = map[*Spec]struct{}{}
+		resolved []*Device
+	)
+
+	for _, device := range devices {
+		d := c.devices[device]
+		if d == nil {
+			unresolved = append(unresolved, device)
+			continue
+		}
+		if _, ok := seen[d.GetSpec()]; !ok {
+			seen[d.GetSpec()] = struct{}{}
+			specs = append(specs, d.GetSpec())
+		}
+		resolved = append(resolved, d)
+	}
+
+	if unresolved != nil {
+		return nil, unresolved, fmt.Errorf("unresolvable CDI devices %s",
+			strings.Join(unresolved, ", "))
+	}
+
+	resolved, err := c.resolveRelations(resolved)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, d := range resolved {
+		if _, ok := seen[d.GetSpec()]; !ok {
+			seen[d.GetSpec()] = struct{}{}
+			specs = append(specs, d.GetSpec())
+		}
+	}
+
+	if err := checkInjectionPolicies(c.policies, specs, resolved); err != nil {
+		return nil, nil, fmt.Errorf("can't inject devices: %w", err)
+	}
+
+	// collect all edits first, so edits shared by several devices, for
+	// instance by a composite and one of its members, are injected once
+	edits := &ContainerEdits{&cdi.ContainerEdits{}}
+	for _, s := range specs {
+		edits.Append(s.edits())
+	}
+	for _, d := range resolved {
+		devEdits := d.edits()
+		if p, ok := perms[d.GetQualifiedName()]; ok {
+			devEdits = devEdits.withPermissions(p)
+		}
+		edits.Append(devEdits)
+	}
+
+	edits, err = edits.Evaluate(c.conditions)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to evaluate conditional edits: %w", err)
+	}
+
+	return edits.dedup(), nil, nil
+}
+
+// resolveRelations adds the devices required by the given devices, and
+// the ones required by those, then checks that none of the resulting
+// devices conflict with each other. The relations of the devices which
+// alias and composite devices refer to are taken into account as well.
+func (c *Cache) resolveRelations(devices []*Device) ([]*Device, error) {
+	var (
+		resolved []*Device
+		injected = map[string]struct{}{}
+		expanded []*Device
+		names    = map[string]struct{}{}
+	)
+
+	var expand func(d *Device)
+	expand = func(d *Device) {
+		if _, ok := names[d.GetQualifiedName()]; ok {
+			return
+		}
+		names[d.GetQualifiedName()] = struct{}{}
+		expanded = append(expanded, d)
+		for _, ref := range d.references() {
+			expand(ref)
+		}
+	}
+	add := func(d *Device) {
+		if _, ok := injected[d.GetQualifiedName()]; ok {
+			return
+		}
+		injected[d.GetQualifiedName()] = struct{}{}
+		resolved = append(resolved, d)
+		expand(d)
+	}
+	for _, d := range devices {
+		add(d)
+	}
+	for i := 0; i < len(expanded); i++ {
+		d := expanded[i]
+		for _, name := range d.GetRequires() {
+			req := c.devices[name]
+			if req == nil {
+				return nil, fmt.Errorf("CDI device %q requires unresolvable device %q",
+					d.GetQualifiedName(), name)
+			}
+			if _, ok := names[name]; ok {
+				continue
+			}
+			add(req)
+		}
+	}
+
+	for _, d := range expanded {
+		for _, name := range d.GetConflicts() {
+			if _, ok := names[name]; ok {
+				return nil, fmt.Errorf("CDI device %q conflicts with device %q",
+					d.GetQualifiedName(), name)
+			}
+		}
+	}
+
+	return resolved, nil
+}
+
+// GetDevice returns the cached device for the given qualified name.
+func (c *Cache) GetDevice(device string) *Device {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	return c.devices[device]
+}
+
+// ListDevices lists all cached devices by qualified name.
+func (c *Cache) ListDevices() []string {
+	var devices []string
+
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	for name := range c.devices {
+		devices = append(devices, name)
+	}
+	sort.Strings(devices)
+
+	return devices
+}
+
+// ListVendors lists all vendors known to the cache.
+func (c *Cache) ListVendors() []string {
+	var vendors []string
+
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	for vendor := range c.specs {
+		vendors = append(vendors, vendor)
+	}
+	sort.Strings(vendors)
+
+	return vendors
+}
+
+// ListClasses lists all device classes known to the cache.
+func (c *Cache) ListClasses() []string {
+	var (
+		cmap    = map[string]struct{}{}
+		classes []string
+	)
+
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	for _, specs := range c.specs {
+		for _, spec := range specs {
+			cmap[spec.GetClass()] = struct{}{}
+		}
+	}
+	for class := range cmap {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	return classes
+}
+
+// GetVendorSpecs returns all specs for the given vendor.
+func (c *Cache) GetVendorSpecs(vendor string) []*Spec {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	return c.specs[vendor]
+}
+
+// GetSpecErrors returns all errors encountered for the spec during the
+// last cache refresh.
+func (c *Cache) GetSpecErrors(spec *Spec) []error {
+	var errors []error
+
+	c.Lock()
+	defer c.Unlock()
+
+	if errs, ok := c.errors[spec.GetPath()]; ok {
+		errors = make([]error, len(errs))
+		copy(errors, errs)
+	}
+
+	return errors
+}
+
+// GetErrors returns all errors encountered during the last
+// cache refresh.
+func (c *Cache) GetErrors() map[string][]error {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	errors := map[string][]error{}
+	for path, errs := range c.errors {
+		errors[path] = errs
+	}
+
+	return errors
+}
+
+// GetWarnings returns all warnings, for instance unknown or duplicate
+// keys found in StrictModeWarn or failed signature verification in
+// SignatureModeWarn, encountered during the last cache refresh.
+func (c *Cache) GetWarnings() map[string][]error {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	warnings := map[string][]error{}
+	for path, warns := range c.warnings {
+		warnings[path] = warns
+	}
+
+	return warnings
+}
+
+// GetSpecDirectories returns the CDI Spec directories currently in use.
+func (c *Cache) GetSpecDirectories() []string {
+	c.Lock()
+	defer c.Unlock()
+
+	dirs := make([]string, len(c.specDirs))
+	copy(dirs, c.specDirs)
+	return dirs
+}
@@ -0,0 +1,120 @@
+package cdi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// DigestAlgorithm is the algorithm prefix of Spec digests.
+	DigestAlgorithm = "sha256"
+)
+
+// CanonicalSpec returns the canonical encoding of a raw CDI Spec. The
+// canonical encoding is compact JSON with object keys sorted, empty
+// optional fields omitted, nil entries dropped and devices sorted by
+// name. Semantically equal Specs have the same canonical encoding,
+// regardless of their original encoding, formatting or key order. The
+// order of container edits is preserved since it is significant during
+// injection.
+func CanonicalSpec(raw *cdi.Spec) ([]byte, error) {
+	data, err := json.Marshal(normalizeSpec(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	// round-trip through generic values to get object keys sorted
+	var obj interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&obj); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(obj); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// Digest returns the digest of the canonical encoding of a raw CDI
+// Spec, in the form "sha256:<hex>". Semantically equal Specs have the
+// same digest. Digest returns an empty string for a nil Spec.
+func Digest(raw *cdi.Spec) string {
+	if raw == nil {
+		return ""
+	}
+	data, err := CanonicalSpec(raw)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return DigestAlgorithm + ":" + hex.EncodeToString(sum[:])
+}
+
+// GetDigest returns the digest of this Spec.
+func (s *Spec) GetDigest() string {
+	return Digest(s.Spec)
+}
+
+// normalizeSpec returns a copy of the Spec with devices sorted by name
+// and nil container edit entries dropped. Empty optional fields need no
+// normalization, they are omitted during encoding.
+func normalizeSpec(raw *cdi.Spec) *cdi.Spec {
+	if raw == nil {
+		return nil
+	}
+
+	spec := *raw
+	spec.ContainerEdits = normalizeEdits(raw.ContainerEdits)
+	spec.Devices = make([]cdi.Device, 0, len(raw.Devices))
+	for _, d := range raw.Devices {
+		d.ContainerEdits = normalizeEdits(d.ContainerEdits)
+		spec.Devices = append(spec.Devices, d)
+	}
+	sort.SliceStable(spec.Devices, func(i, j int) bool {
+		return spec.Devices[i].Name < spec.Devices[j].Name
+	})
+
+	return &spec
+}
+
+// normalizeEdits returns a copy of the edits, including conditional
+// ones, with nil entries dropped.
+func normalizeEdits(e cdi.ContainerEdits) cdi.ContainerEdits {
+	edits := cdi.ContainerEdits{
+		Env: e.Env,
+	}
+	for _, d := range e.DeviceNodes {
+		if d != nil {
+			edits.DeviceNodes = append(edits.DeviceNodes, d)
+		}
+	}
+	for _, h := range e.Hooks {
+		if h != nil {
+			edits.Hooks = append(edits.Hooks, h)
+		}
+	}
+	for _, m := range e.Mounts {
+		if m != nil {
+			edits.Mounts = append(edits.Mounts, m)
+		}
+	}
+	for _, c := range e.Conditional {
+		if c != nil {
+			cond := *c
+			cond.ContainerEdits = normalizeEdits(c.ContainerEdits)
+			edits.Conditional = append(edits.Conditional, &cond)
+		}
+	}
+	return edits
+}
@@ -0,0 +1,134 @@
+package cdi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+func TestCanonicalSpec(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      []string
+		canonical string
+	}{
+		{
+			name: "formatting, key order and device order",
+			data: []string{
+				`cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev1
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev1
+  - name: dev0
+    containerEdits:
+      env: [ "FOO=bar" ]
+`,
+				`{"kind":"vendor.com/device","cdiVersion":"0.3.0","devices":[
+  {"containerEdits":{"env":["FOO=bar"]},"name":"dev0"},
+  {"name":"dev1","containerEdits":{"deviceNodes":[{"path":"/dev/dev1"}]}}]}`,
+			},
+			canonical: `{"cdiVersion":"0.3.0","containerEdits":{},"devices":[{"containerEdits":{"env":["FOO=bar"]},"name":"dev0"},{"containerEdits":{"deviceNodes":[{"path":"/dev/dev1"}]},"name":"dev1"}],"kind":"vendor.com/device"}`,
+		},
+		{
+			name: "empty optional fields",
+			data: []string{
+				`cdiVersion: "0.3.0"
+kind: vendor.com/device
+annotations: {}
+containerEdits:
+  env: []
+  mounts: []
+devices:
+  - name: dev0
+    annotations: {}
+    containerEdits:
+      hooks:
+        - hookName: createContainer
+          path: /bin/hook
+          args: []
+`,
+				`cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      hooks:
+        - hookName: createContainer
+          path: /bin/hook
+`,
+			},
+			canonical: `{"cdiVersion":"0.3.0","containerEdits":{},"devices":[{"containerEdits":{"hooks":[{"hookName":"createContainer","path":"/bin/hook"}]},"name":"dev0"}],"kind":"vendor.com/device"}`,
+		},
+		{
+			name: "conditional edits",
+			data: []string{
+				`cdiVersion: "0.8.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      conditional:
+        - arch: [ amd64 ]
+          containerEdits:
+            hooks:
+              - hookName: createContainer
+                path: /bin/hook
+            mounts: []
+`,
+				`{"cdiVersion":"0.8.0","kind":"vendor.com/device","devices":[{"name":"dev0",
+  "containerEdits":{"conditional":[{"containerEdits":{"hooks":[
+  {"path":"/bin/hook","hookName":"createContainer"}]},"arch":["amd64"]}]}}]}`,
+			},
+			canonical: `{"cdiVersion":"0.8.0","containerEdits":{},"devices":[{"containerEdits":{"conditional":[{"arch":["amd64"],"containerEdits":{"hooks":[{"hookName":"createContainer","path":"/bin/hook"}]}}]},"name":"dev0"}],"kind":"vendor.com/device"}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var digest string
+			for _, data := range tc.data {
+				raw, err := ParseSpec([]byte(data))
+				require.NoError(t, err)
+
+				canonical, err := CanonicalSpec(raw)
+				require.NoError(t, err)
+				require.Equal(t, tc.canonical, string(canonical))
+
+				if digest == "" {
+					digest = Digest(raw)
+					require.Regexp(t, "^sha256:[0-9a-f]{64}$", digest)
+				} else {
+					require.Equal(t, digest, Digest(raw))
+				}
+			}
+		})
+	}
+}
+
+func TestDigestConditionalEdits(t *testing.T) {
+	raw, err := ParseSpec([]byte(`cdiVersion: "0.8.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+`))
+	require.NoError(t, err)
+	digest := Digest(raw)
+
+	raw.Devices[0].ContainerEdits.Conditional = append(raw.Devices[0].ContainerEdits.Conditional,
+		&cdi.ConditionalEdits{
+			Arch: []string{"amd64"},
+			ContainerEdits: cdi.ContainerEdits{
+				Hooks: []*cdi.Hook{{HookName: "createContainer", Path: "/tmp/evil"}},
+			},
+		},
+	)
+	require.NotEqual(t, digest, Digest(raw))
+}
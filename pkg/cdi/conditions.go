@@ -0,0 +1,221 @@
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"container-device-interface-aaron/specs-go"
+)
+
+// ConditionEvaluator provides the host properties the conditions of
+// conditional container edits are evaluated against.
+type ConditionEvaluator interface {
+	// Arch returns the host architecture.
+	Arch() string
+	// KernelVersion returns the host kernel version.
+	KernelVersion() (string, error)
+	// PathExists checks if the given host path exists.
+	PathExists(path string) bool
+}
+
+// HostConditions evaluates conditions against the running host.
+var HostConditions ConditionEvaluator = hostConditions{}
+
+// WithConditionEvaluator returns an option to set the evaluator used
+// for the conditions of conditional container edits during injection.
+func WithConditionEvaluator(evaluator ConditionEvaluator) Option {
+	return func(c *Cache) error {
+		c.conditions = evaluator
+		return nil
+	}
+}
+
+// hostConditions is the ConditionEvaluator for the running host.
+type hostConditions struct{}
+
+// Arch returns the architecture of the running host.
+func (hostConditions) Arch() string {
+	return runtime.GOARCH
+}
+
+// KernelVersion returns the kernel version of the running host.
+func (hostConditions) KernelVersion() (string, error) {
+	data, err := os.ReadFile("/proc/sys/kernel/osrelease")
+	if err != nil {
+		return "", fmt.Errorf("failed to read kernel version: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// PathExists checks if the given path exists on the running host.
+func (hostConditions) PathExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// archAliases maps alternative architecture names, as reported by uname,
+// to the corresponding Go ones.
+var archAliases = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+	"i386":    "386",
+	"i686":    "386",
+	"armv7l":  "arm",
+}
+
+// normalizeArch returns the Go name of an architecture.
+func normalizeArch(arch string) string {
+	if alias, ok := archAliases[arch]; ok {
+		return alias
+	}
+	return arch
+}
+
+// Evaluate returns a copy of the edits with the edits of conditional
+// entries merged in if their conditions hold, and dropped otherwise. A
+// nil evaluator evaluates the conditions against the running host.
+func (e *ContainerEdits) Evaluate(evaluator ConditionEvaluator) (*ContainerEdits, error) {
+	if e == nil || e.ContainerEdits == nil || len(e.Conditional) == 0 {
+		return e, nil
+	}
+	if evaluator == nil {
+		evaluator = HostConditions
+	}
+
+	// copy the slices, appending to them must not modify cached Specs
+	edits := *e.ContainerEdits
+	edits.Env = append([]string(nil), e.Env...)
+	edits.DeviceNodes = append([]*specs.DeviceNode(nil), e.DeviceNodes...)
+	edits.Mounts = append([]*specs.Mount(nil), e.Mounts...)
+	edits.Hooks = append([]*specs.Hook(nil), e.Hooks...)
+	edits.Conditional = nil
+	result := &ContainerEdits{&edits}
+	for _, c := range e.Conditional {
+		ok, err := (&ConditionalEdits{c}).matches(evaluator)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result.Append(&ContainerEdits{&c.ContainerEdits})
+		}
+	}
+
+	return result, nil
+}
+
+// ConditionalEdits is a CDI Spec ConditionalEdits wrapper, used for
+// validating and evaluating conditional edits.
+type ConditionalEdits struct {
+	*specs.ConditionalEdits
+}
+
+// Validate conditional edits.
+func (c *ConditionalEdits) Validate() error {
+	if len(c.Arch) == 0 && c.MinKernelVersion == "" && c.IfExists == "" {
+		return fmt.Errorf("invalid conditional edits, no conditions")
+	}
+	for _, arch := range c.Arch {
+		if arch == "" {
+			return fmt.Errorf("invalid conditional edits, empty arch")
+		}
+	}
+	if c.MinKernelVersion != "" {
+		if _, err := parseKernelVersion(c.MinKernelVersion); err != nil {
+			return fmt.Errorf("invalid conditional edits: %w", err)
+		}
+	}
+	if c.IfExists != "" && !filepath.IsAbs(c.IfExists) {
+		return fmt.Errorf("invalid conditional edits, relative path %q", c.IfExists)
+	}
+	if len(c.ContainerEdits.Conditional) > 0 {
+		return fmt.Errorf("invalid conditional edits, nested conditional edits")
+	}
+	return (&ContainerEdits{&c.ContainerEdits}).Validate()
+}
+
+// matches checks if all conditions hold on the host.
+func (c *ConditionalEdits) matches(evaluator ConditionEvaluator) (bool, error) {
+	if len(c.Arch) > 0 {
+		arch, found := normalizeArch(evaluator.Arch()), false
+		for _, a := range c.Arch {
+			if normalizeArch(a) == arch {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+
+	if c.MinKernelVersion != "" {
+		kernel, err := evaluator.KernelVersion()
+		if err != nil {
+			return false, err
+		}
+		have, err := parseKernelVersion(kernel)
+		if err != nil {
+			return false, err
+		}
+		min, err := parseKernelVersion(c.MinKernelVersion)
+		if err != nil {
+			return false, err
+		}
+		if compareKernelVersions(have, min) < 0 {
+			return false, nil
+		}
+	}
+
+	if c.IfExists != "" && !evaluator.PathExists(c.IfExists) {
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// parseKernelVersion parses the numeric parts of a kernel version, such
+// as 5.15 or 5.15.0-91-generic. Anything after the numeric parts is
+// ignored.
+func parseKernelVersion(version string) ([]int, error) {
+	numeric := version
+	if i := strings.IndexFunc(version, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	}); i >= 0 {
+		numeric = version[:i]
+	}
+
+	var parts []int
+	for _, p := range strings.Split(numeric, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid kernel version %q", version)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// compareKernelVersions compares two parsed kernel versions, returning
+// -1, 0 or 1 if a is lower than, equal to or greater than b.
+func compareKernelVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
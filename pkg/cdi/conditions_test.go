@@ -0,0 +1,226 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+type fakeConditions struct {
+	arch   string
+	kernel string
+	paths  map[string]bool
+}
+
+func (f *fakeConditions) Arch() string                   { return f.arch }
+func (f *fakeConditions) KernelVersion() (string, error) { return f.kernel, nil }
+func (f *fakeConditions) PathExists(path string) bool    { return f.paths[path] }
+
+func TestConditionalEdits(t *testing.T) {
+	const specData = `cdiVersion: "0.8.0"
+kind: vendor.com/gpu
+containerEdits:
+  conditional:
+    - arch: [x86_64]
+      containerEdits:
+        env: ["VENDOR_ARCH=x86_64"]
+    - arch: [aarch64, armv7l]
+      containerEdits:
+        env: ["VENDOR_ARCH=arm"]
+devices:
+  - name: gpu0
+    containerEdits:
+      env: ["VENDOR_GPU=0"]
+      conditional:
+        - minKernelVersion: "5.15"
+          containerEdits:
+            env: ["VENDOR_NEW_KERNEL=1"]
+        - ifExists: /usr/lib/vendor/libfw.so
+          minKernelVersion: "5.4"
+          containerEdits:
+            env: ["VENDOR_FW=1"]
+`
+	testCases := []struct {
+		name       string
+		conditions *fakeConditions
+		env        []string
+	}{
+		{
+			name: "amd64, old kernel, no firmware",
+			conditions: &fakeConditions{
+				arch:   "amd64",
+				kernel: "5.4.0-150-generic",
+			},
+			env: []string{"VENDOR_GPU=0", "VENDOR_ARCH=x86_64"},
+		},
+		{
+			name: "arm64, new kernel, firmware",
+			conditions: &fakeConditions{
+				arch:   "arm64",
+				kernel: "6.1.0-rc1",
+				paths:  map[string]bool{"/usr/lib/vendor/libfw.so": true},
+			},
+			env: []string{"VENDOR_GPU=0", "VENDOR_ARCH=arm", "VENDOR_NEW_KERNEL=1", "VENDOR_FW=1"},
+		},
+		{
+			name: "other arch, too old kernel for firmware",
+			conditions: &fakeConditions{
+				arch:   "riscv64",
+				kernel: "4.19",
+				paths:  map[string]bool{"/usr/lib/vendor/libfw.so": true},
+			},
+			env: []string{"VENDOR_GPU=0"},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor.yaml"), []byte(specData), 0o644))
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cache, err := NewCache(WithSpecDirs(dir), WithConditionEvaluator(tc.conditions))
+			require.NoError(t, err)
+			require.Empty(t, cache.GetErrors())
+
+			spec := &oci.Spec{}
+			_, err = cache.InjectDevices(spec, "vendor.com/gpu=gpu0")
+			require.NoError(t, err)
+			require.Equal(t, tc.env, spec.Process.Env)
+		})
+	}
+}
+
+func TestValidateConditionalEdits(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		invalid bool
+	}{
+		{
+			name: "valid",
+			data: `  conditional:
+    - arch: [arm64]
+      minKernelVersion: "5.10.0"
+      ifExists: /dev/vendorctl
+      containerEdits:
+        env: ["A=1"]
+`,
+		},
+		{
+			name: "no conditions",
+			data: `  conditional:
+    - containerEdits:
+        env: ["A=1"]
+`,
+			invalid: true,
+		},
+		{
+			name: "invalid kernel version",
+			data: `  conditional:
+    - minKernelVersion: latest
+      containerEdits:
+        env: ["A=1"]
+`,
+			invalid: true,
+		},
+		{
+			name: "relative path",
+			data: `  conditional:
+    - ifExists: dev/vendorctl
+      containerEdits:
+        env: ["A=1"]
+`,
+			invalid: true,
+		},
+		{
+			name: "nested",
+			data: `  conditional:
+    - arch: [arm64]
+      containerEdits:
+        conditional:
+          - arch: [arm64]
+            containerEdits:
+              env: ["A=1"]
+`,
+			invalid: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			data := `cdiVersion: "0.8.0"
+kind: vendor.com/gpu
+containerEdits:
+` + tc.data + `devices:
+  - name: gpu0
+    containerEdits:
+      env: ["VENDOR_GPU=0"]
+`
+			raw, err := ParseSpec([]byte(data))
+			require.NoError(t, err)
+			_, err = newSpec(raw, "vendor.yaml", 0)
+			if tc.invalid {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestConcurrentConditionalApply(t *testing.T) {
+	raw := &cdi.Spec{
+		Version: "0.8.0",
+		Kind:    "vendor.com/gpu",
+		Devices: []cdi.Device{
+			{
+				Name: "gpu0",
+				ContainerEdits: cdi.ContainerEdits{
+					// spare capacity, so appending in place would write
+					// into the backing array of the device edits
+					Env: append(make([]string, 0, 8), "VENDOR_GPU=0"),
+					Conditional: []*cdi.ConditionalEdits{
+						{
+							Arch: []string{runtime.GOARCH},
+							ContainerEdits: cdi.ContainerEdits{
+								Env: []string{"VENDOR_ARCH=1"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	spec, err := newSpec(raw, "/etc/cdi/vendor.yaml", 0)
+	require.NoError(t, err)
+	dev := spec.GetDevice("gpu0")
+	require.NotNil(t, dev)
+
+	const workers = 16
+	var (
+		wg   sync.WaitGroup
+		envs = make([][]string, workers)
+		errs = make([]error, workers)
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ociSpec := &oci.Spec{}
+			errs[i] = dev.ApplyEdits(ociSpec)
+			envs[i] = ociSpec.Process.Env
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < workers; i++ {
+		require.NoError(t, errs[i])
+		require.Equal(t, []string{"VENDOR_GPU=0", "VENDOR_ARCH=1"}, envs[i])
+	}
+	require.Equal(t, []string{"VENDOR_GPU=0"}, dev.ContainerEdits.Env)
+}
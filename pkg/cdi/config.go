@@ -0,0 +1,254 @@
+package cdi
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"container-device-interface-aaron/schema"
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// DefaultConfigFile is the default CDI registry configuration file.
+	DefaultConfigFile = "/etc/cdi/cdi.conf"
+
+	// ConfigFileEnv overrides the configuration file path.
+	ConfigFileEnv = "CDI_CONFIG"
+	// SpecDirsEnv overrides the configured Spec directories with a
+	// colon-separated list, in increasing order of priority.
+	SpecDirsEnv = "CDI_SPEC_DIRS"
+	// StrictModeEnv overrides the configured strict mode.
+	StrictModeEnv = "CDI_STRICT_MODE"
+	// SchemaEnv overrides the configured JSON schema.
+	SchemaEnv = "CDI_SCHEMA"
+	// RefreshIntervalEnv overrides the configured refresh interval.
+	RefreshIntervalEnv = "CDI_REFRESH_INTERVAL"
+)
+
+// Config is the configuration of a CDI registry, usually read from
+// DefaultConfigFile.
+type Config struct {
+	// SpecDirs are the Spec directories with their priorities. If
+	// omitted, DefaultSpecDirs are used.
+	SpecDirs []*SpecDirConfig `json:"specDirs,omitempty"`
+	// StrictMode is the strict decoding mode, disabled, warn or error.
+	StrictMode string `json:"strictMode,omitempty"`
+	// Schema is the JSON schema Specs are validated against, builtin,
+	// none or a path or URL. Specs are not validated if omitted.
+	Schema string `json:"schema,omitempty"`
+	// RefreshInterval is the minimum time between automatic refreshes,
+	// as a duration like "30s". Automatic refresh is disabled if omitted.
+	RefreshInterval string `json:"refreshInterval,omitempty"`
+	// Signatures is the Spec signature verification configuration.
+	Signatures *SignatureConfig `json:"signatures,omitempty"`
+	// Policies are the enabled policies restricting Spec edits.
+	Policies []*Policy `json:"policies,omitempty"`
+	// SecureLoading is the secure Spec loading configuration.
+	SecureLoading *SecureLoadingConfig `json:"secureLoading,omitempty"`
+}
+
+// SpecDirConfig is the configuration of a Spec directory.
+type SpecDirConfig struct {
+	// Path is the path of the directory.
+	Path string `json:"path"`
+	// Priority is the priority of the directory. Devices from Specs in
+	// directories with a higher priority override ones with a lower.
+	// Priorities must be unique.
+	Priority int `json:"priority"`
+}
+
+// SignatureConfig is the Spec signature verification configuration.
+type SignatureConfig struct {
+	// Mode is the signature mode, disabled, warn or error.
+	Mode string `json:"mode"`
+	// TrustDir is the directory of trusted vendor public keys.
+	TrustDir string `json:"trustDir,omitempty"`
+}
+
+// SecureLoadingConfig is the secure Spec loading configuration.
+type SecureLoadingConfig struct {
+	// Enabled enables secure loading.
+	Enabled bool `json:"enabled"`
+	// UIDs are the UIDs allowed to own Spec files, root if omitted.
+	UIDs []uint32 `json:"uids,omitempty"`
+}
+
+var (
+	strictModes = map[string]StrictMode{
+		"disabled": StrictModeDisabled,
+		"warn":     StrictModeWarn,
+		"error":    StrictModeError,
+	}
+	signatureModes = map[string]SignatureMode{
+		"disabled": SignatureModeDisabled,
+		"warn":     SignatureModeWarn,
+		"error":    SignatureModeError,
+	}
+)
+
+// ReadConfig reads the given CDI registry configuration file.
+func ReadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CDI config: %w", err)
+	}
+
+	cfg := &Config{}
+	if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse CDI config %q: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// NewRegistryFromConfig creates a new Cache configured by the given
+// configuration file and the environment variable overrides. An empty
+// path selects $CDI_CONFIG, or DefaultConfigFile which is then allowed
+// to be missing. The given options are applied after the configuration.
+func NewRegistryFromConfig(path string, options ...Option) (*Cache, error) {
+	optional := false
+	if path == "" {
+		path = os.Getenv(ConfigFileEnv)
+	}
+	if path == "" {
+		path, optional = DefaultConfigFile, true
+	}
+
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		if !optional || !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		cfg = &Config{}
+	}
+	cfg.applyEnv()
+
+	cfgOptions, err := cfg.Options()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CDI config %q: %w", path, err)
+	}
+
+	return NewCache(append(cfgOptions, options...)...)
+}
+
+// applyEnv applies the environment variable overrides to the config.
+func (cfg *Config) applyEnv() {
+	if dirs, ok := os.LookupEnv(SpecDirsEnv); ok {
+		cfg.SpecDirs = nil
+		for i, dir := range filepath.SplitList(dirs) {
+			cfg.SpecDirs = append(cfg.SpecDirs, &SpecDirConfig{Path: dir, Priority: i})
+		}
+	}
+	if mode, ok := os.LookupEnv(StrictModeEnv); ok {
+		cfg.StrictMode = mode
+	}
+	if name, ok := os.LookupEnv(SchemaEnv); ok {
+		cfg.Schema = name
+	}
+	if interval, ok := os.LookupEnv(RefreshIntervalEnv); ok {
+		cfg.RefreshInterval = interval
+	}
+}
+
+// withSpecDirPriorities returns an option to set the CDI Spec directories
+// with explicit priorities, in increasing order of priority. Specs keep
+// the priority of their directory.
+func withSpecDirPriorities(dirs []string, priorities []int) Option {
+	return func(c *Cache) error {
+		if err := WithSpecDirs(dirs...)(c); err != nil {
+			return err
+		}
+		c.dirPriorities = priorities
+		return nil
+	}
+}
+
+// Options returns the Cache options for the configuration.
+func (cfg *Config) Options() ([]Option, error) {
+	var options []Option
+
+	if len(cfg.SpecDirs) > 0 {
+		dirs := make([]*SpecDirConfig, len(cfg.SpecDirs))
+		copy(dirs, cfg.SpecDirs)
+		sort.SliceStable(dirs, func(i, j int) bool {
+			return dirs[i].Priority < dirs[j].Priority
+		})
+		var (
+			paths      []string
+			priorities []int
+		)
+		for i, d := range dirs {
+			if d.Path == "" {
+				return nil, fmt.Errorf("empty Spec directory path")
+			}
+			if i > 0 && d.Priority == dirs[i-1].Priority {
+				return nil, fmt.Errorf("Spec directories %q and %q have the same priority %d",
+					dirs[i-1].Path, d.Path, d.Priority)
+			}
+			paths = append(paths, d.Path)
+			priorities = append(priorities, d.Priority)
+		}
+		options = append(options, withSpecDirPriorities(paths, priorities))
+	}
+
+	if cfg.StrictMode != "" {
+		mode, ok := strictModes[cfg.StrictMode]
+		if !ok {
+			return nil, fmt.Errorf("invalid strict mode %q", cfg.StrictMode)
+		}
+		options = append(options, WithStrictMode(mode))
+	}
+
+	if cfg.Schema != "" {
+		scm, err := schema.Load(cfg.Schema)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, WithSpecValidator(func(raw *cdi.Spec) error {
+			return scm.ValidateType(raw)
+		}))
+	}
+
+	if cfg.RefreshInterval != "" {
+		interval, err := time.ParseDuration(cfg.RefreshInterval)
+		if err != nil {
+			return nil, fmt.Errorf("invalid refresh interval: %w", err)
+		}
+		options = append(options, WithAutoRefresh(interval))
+	}
+
+	if sig := cfg.Signatures; sig != nil {
+		mode, ok := signatureModes[sig.Mode]
+		if !ok {
+			return nil, fmt.Errorf("invalid signature mode %q", sig.Mode)
+		}
+		var store *TrustStore
+		if mode != SignatureModeDisabled {
+			if sig.TrustDir == "" {
+				return nil, fmt.Errorf("signature verification requires a trust directory")
+			}
+			var err error
+			if store, err = LoadTrustStore(sig.TrustDir); err != nil {
+				return nil, err
+			}
+		}
+		options = append(options, WithSignatureVerification(store, mode))
+	}
+
+	if len(cfg.Policies) > 0 {
+		options = append(options, WithPolicies(cfg.Policies...))
+	}
+
+	if sec := cfg.SecureLoading; sec != nil && sec.Enabled {
+		options = append(options, WithSecureLoading(sec.UIDs...))
+	}
+
+	return options, nil
+}
@@ -0,0 +1,200 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRegistryFromConfig(t *testing.T) {
+	const (
+		lowSpec = `cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["PRIORITY=low"]
+`
+		highSpec = `cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["PRIORITY=high"]
+`
+		unknownKeySpec = `cdiVersion: "0.5.0"
+kind: vendor.com/other
+devices:
+  - name: dev0
+    unknown: true
+    containerEdits:
+      env: ["OTHER=1"]
+`
+	)
+
+	writeDir := func(t *testing.T, files map[string]string) string {
+		dir := t.TempDir()
+		for name, data := range files {
+			require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+		}
+		return dir
+	}
+
+	for _, tc := range []struct {
+		name     string
+		config   string
+		env      map[string]string
+		priority string
+		prio     int
+		devices  int
+		invalid  bool
+	}{
+		{
+			name: "explicit priorities",
+			config: `specDirs:
+  - path: {{high}}
+    priority: 20
+  - path: {{low}}
+    priority: 10
+schema: builtin
+`,
+			priority: "high",
+			prio:     20,
+			devices:  2,
+		},
+		{
+			name: "strict mode and refresh interval",
+			config: `specDirs:
+  - path: {{low}}
+    priority: 20
+  - path: {{high}}
+    priority: 10
+strictMode: error
+refreshInterval: 1m
+`,
+			priority: "low",
+			prio:     20,
+			devices:  1,
+		},
+		{
+			name: "environment overrides",
+			config: `specDirs:
+  - path: {{low}}
+    priority: 20
+strictMode: error
+`,
+			env: map[string]string{
+				SpecDirsEnv:   "{{low}}:{{high}}",
+				StrictModeEnv: "disabled",
+			},
+			priority: "high",
+			prio:     1,
+			devices:  2,
+		},
+		{
+			name: "priorities override configuration order",
+			config: `specDirs:
+  - path: {{high}}
+    priority: 1
+  - path: {{low}}
+    priority: -1
+  - path: {{empty}}
+    priority: 0
+`,
+			priority: "high",
+			prio:     1,
+			devices:  2,
+		},
+		{
+			name: "secure loading with explicit priorities",
+			config: `specDirs:
+  - path: {{low}}
+    priority: 10
+  - path: {{high}}
+    priority: 20
+secureLoading:
+  enabled: true
+  uids: [{{uid}}]
+`,
+			priority: "high",
+			prio:     20,
+			devices:  2,
+		},
+		{
+			name: "duplicate priorities",
+			config: `specDirs:
+  - path: {{high}}
+    priority: 10
+  - path: {{low}}
+    priority: 10
+`,
+			invalid: true,
+		},
+		{
+			name:    "invalid strict mode",
+			config:  "strictMode: sometimes\n",
+			invalid: true,
+		},
+		{
+			name:    "invalid refresh interval",
+			config:  "refreshInterval: often\n",
+			invalid: true,
+		},
+		{
+			name:    "unknown key",
+			config:  "specDir: /etc/cdi\n",
+			invalid: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			low := writeDir(t, map[string]string{"vendor.yaml": lowSpec, "other.yaml": unknownKeySpec})
+			high := writeDir(t, map[string]string{"vendor.yaml": highSpec})
+			empty := t.TempDir()
+			uid := strconv.Itoa(os.Getuid())
+			replace := strings.NewReplacer("{{low}}", low, "{{high}}", high, "{{empty}}", empty,
+				"{{uid}}", uid).Replace
+
+			path := filepath.Join(t.TempDir(), "cdi.conf")
+			require.NoError(t, ioutil.WriteFile(path, []byte(replace(tc.config)), 0o644))
+			for k, v := range tc.env {
+				t.Setenv(k, replace(v))
+			}
+
+			cache, err := NewRegistryFromConfig(path)
+			if tc.invalid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, cache.ListDevices(), tc.devices)
+
+			dev := cache.GetDevice("vendor.com/device=dev0")
+			require.NotNil(t, dev)
+			require.Equal(t, []string{"PRIORITY=" + tc.priority}, dev.ContainerEdits.Env)
+			require.Equal(t, tc.prio, dev.GetSpec().GetPriority())
+		})
+	}
+}
+
+func TestAutoRefresh(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewCache(WithSpecDirs(dir), WithAutoRefresh(time.Nanosecond))
+	require.NoError(t, err)
+	require.Empty(t, cache.ListDevices())
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["DEV0=1"]
+`), 0o644))
+	time.Sleep(time.Millisecond)
+	require.Equal(t, []string{"vendor.com/device=dev0"}, cache.ListDevices())
+}
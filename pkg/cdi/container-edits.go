@@ -1,7 +1,16 @@
 package cdi
 
 import (
-	"github.com/container-orchestrated-devices/container-device-interface/specs-go"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/specs-go"
 )
 
 const (
@@ -41,3 +50,410 @@ var (
 type ContainerEdits struct {
 	*specs.ContainerEdits
 }
+
+// Apply edits to the given OCI Spec. Updates the OCI Spec in place.
+// Conditional edits are evaluated against the running host. Returns an
+// error if the update fails.
+func (e *ContainerEdits) Apply(spec *oci.Spec) error {
+	if spec == nil {
+		return errors.New("can't edit nil OCI Spec")
+	}
+	if e == nil || e.ContainerEdits == nil {
+		return nil
+	}
+
+	e, err := e.Evaluate(HostConditions)
+	if err != nil {
+		return fmt.Errorf("failed to evaluate conditional edits: %w", err)
+	}
+
+	if len(e.Env) > 0 {
+		ensureOCIProcess(spec)
+		spec.Process.Env = mergeEnv(spec.Process.Env, e.Env)
+	}
+
+	for _, d := range e.DeviceNodes {
+		node := *d
+		dn := &DeviceNode{&node}
+
+		if err := dn.fillMissingInfo(); err != nil {
+			return err
+		}
+		dev := dn.ToOCI()
+		if dev.UID == nil && spec.Process != nil {
+			if uid := spec.Process.User.UID; uid > 0 {
+				dev.UID = &uid
+			}
+		}
+		if dev.GID == nil && spec.Process != nil {
+			if gid := spec.Process.User.GID; gid > 0 {
+				dev.GID = &gid
+			}
+		}
+
+		ensureOCILinux(spec)
+		removeOCIDevice(spec, dev.Path)
+		spec.Linux.Devices = append(spec.Linux.Devices, dev)
+
+		if dev.Type == "b" || dev.Type == "c" {
+			access := d.Permissions
+			if access == "" {
+				access = "rwm"
+			}
+			if spec.Linux.Resources == nil {
+				spec.Linux.Resources = &oci.LinuxResources{}
+			}
+			major, minor := dev.Major, dev.Minor
+			spec.Linux.Resources.Devices = append(spec.Linux.Resources.Devices,
+				oci.LinuxDeviceCgroup{
+					Allow:  true,
+					Type:   dev.Type,
+					Major:  &major,
+					Minor:  &minor,
+					Access: access,
+				})
+		}
+	}
+
+	if len(e.Mounts) > 0 {
+		for _, m := range e.Mounts {
+			removeOCIMount(spec, m.ContainerPath)
+			spec.Mounts = append(spec.Mounts, m.ToOCI())
+		}
+		sort.Stable(orderedMounts(spec.Mounts))
+	}
+
+	for _, h := range e.Hooks {
+		ensureOCIHooks(spec)
+		switch h.HookName {
+		case PrestartHook:
+			spec.Hooks.Prestart = append(spec.Hooks.Prestart, h.ToOCI())
+		case CreateRuntimeHook:
+			spec.Hooks.CreateRuntime = append(spec.Hooks.CreateRuntime, h.ToOCI())
+		case CreateContainerHook:
+			spec.Hooks.CreateContainer = append(spec.Hooks.CreateContainer, h.ToOCI())
+		case StartContainerHook:
+			spec.Hooks.StartContainer = append(spec.Hooks.StartContainer, h.ToOCI())
+		case PoststartHook:
+			spec.Hooks.Poststart = append(spec.Hooks.Poststart, h.ToOCI())
+		case PoststopHook:
+			spec.Hooks.Poststop = append(spec.Hooks.Poststop, h.ToOCI())
+		default:
+			return fmt.Errorf("unknown hook name %q", h.HookName)
+		}
+	}
+
+	return nil
+}
+
+// Validate container edits.
+func (e *ContainerEdits) Validate() error {
+	if e == nil || e.ContainerEdits == nil {
+		return nil
+	}
+
+	if err := ValidateEnv(e.Env); err != nil {
+		return fmt.Errorf("invalid container edits: %w", err)
+	}
+	for _, d := range e.DeviceNodes {
+		if err := (&DeviceNode{d}).Validate(); err != nil {
+			return err
+		}
+	}
+	for _, h := range e.Hooks {
+		if err := (&Hook{h}).Validate(); err != nil {
+			return err
+		}
+	}
+	for _, m := range e.Mounts {
+		if err := (&Mount{m}).Validate(); err != nil {
+			return err
+		}
+	}
+	for _, c := range e.Conditional {
+		if err := (&ConditionalEdits{c}).Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Append other edits into this one. If called with a nil receiver,
+// allocates and returns newly allocated edits.
+func (e *ContainerEdits) Append(o *ContainerEdits) *ContainerEdits {
+	if o == nil || o.ContainerEdits == nil {
+		return e
+	}
+	if e == nil {
+		e = &ContainerEdits{}
+	}
+	if e.ContainerEdits == nil {
+		e.ContainerEdits = &specs.ContainerEdits{}
+	}
+
+	e.Env = append(e.Env, o.Env...)
+	e.DeviceNodes = append(e.DeviceNodes, o.DeviceNodes...)
+	e.Hooks = append(e.Hooks, o.Hooks...)
+	e.Mounts = append(e.Mounts, o.Mounts...)
+	e.Conditional = append(e.Conditional, o.Conditional...)
+
+	return e
+}
+
+// withPermissions returns a copy of the edits with the permissions of
+// all device nodes set to the given ones.
+func (e *ContainerEdits) withPermissions(perms string) *ContainerEdits {
+	if e == nil || e.ContainerEdits == nil {
+		return e
+	}
+
+	edits := *e.ContainerEdits
+	edits.DeviceNodes = make([]*specs.DeviceNode, 0, len(e.DeviceNodes))
+	for _, d := range e.DeviceNodes {
+		node := *d
+		node.Permissions = perms
+		edits.DeviceNodes = append(edits.DeviceNodes, &node)
+	}
+	edits.Conditional = make([]*specs.ConditionalEdits, 0, len(e.Conditional))
+	for _, c := range e.Conditional {
+		cond := *c
+		cond.ContainerEdits = *(&ContainerEdits{&c.ContainerEdits}).withPermissions(perms).ContainerEdits
+		edits.Conditional = append(edits.Conditional, &cond)
+	}
+
+	return &ContainerEdits{&edits}
+}
+
+// dedup returns a copy of the edits with duplicate entries dropped. Only
+// entries identical to an earlier one are dropped, which does not change
+// the result of applying the edits.
+func (e *ContainerEdits) dedup() *ContainerEdits {
+	if e == nil || e.ContainerEdits == nil {
+		return e
+	}
+
+	var (
+		edits = &specs.ContainerEdits{}
+		seen  = map[string]bool{}
+	)
+	isNew := func(kind string, entry interface{}) bool {
+		key := kind + ":" + encodeEntry(entry)
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+		return true
+	}
+
+	for _, env := range e.Env {
+		if isNew("env", env) {
+			edits.Env = append(edits.Env, env)
+		}
+	}
+	for _, d := range e.DeviceNodes {
+		if isNew("deviceNode", d) {
+			edits.DeviceNodes = append(edits.DeviceNodes, d)
+		}
+	}
+	for _, h := range e.Hooks {
+		if isNew("hook", h) {
+			edits.Hooks = append(edits.Hooks, h)
+		}
+	}
+	for _, m := range e.Mounts {
+		if isNew("mount", m) {
+			edits.Mounts = append(edits.Mounts, m)
+		}
+	}
+	for _, c := range e.Conditional {
+		if isNew("conditional", c) {
+			edits.Conditional = append(edits.Conditional, c)
+		}
+	}
+
+	return &ContainerEdits{edits}
+}
+
+// isEmpty returns true if these edits are empty. This is valid in a
+// global Spec context but invalid in a Device context.
+func (e *ContainerEdits) isEmpty() bool {
+	if e == nil {
+		return false
+	}
+	return len(e.Env)+len(e.DeviceNodes)+len(e.Hooks)+len(e.Mounts)+len(e.Conditional) == 0
+}
+
+// ValidateEnv validates the given environment variables.
+func ValidateEnv(env []string) error {
+	for _, v := range env {
+		if strings.IndexByte(v, byte('=')) <= 0 {
+			return fmt.Errorf("invalid environment variable %q", v)
+		}
+	}
+	return nil
+}
+
+// DeviceNode is a CDI Spec DeviceNode wrapper, used for validating DeviceNodes.
+type DeviceNode struct {
+	*specs.DeviceNode
+}
+
+// Validate a CDI Spec DeviceNode.
+func (d *DeviceNode) Validate() error {
+	validTypes := map[string]struct{}{
+		"":  {},
+		"b": {},
+		"c": {},
+		"u": {},
+		"p": {},
+	}
+
+	if d.Path == "" {
+		return errors.New("invalid (empty) device path")
+	}
+	if _, ok := validTypes[d.Type]; !ok {
+		return fmt.Errorf("device %q: invalid type %q", d.Path, d.Type)
+	}
+	for _, bit := range d.Permissions {
+		if bit != 'r' && bit != 'w' && bit != 'm' {
+			return fmt.Errorf("device %q: invalid permissions %q",
+				d.Path, d.Permissions)
+		}
+	}
+	return nil
+}
+
+// Hook is a CDI Spec Hook wrapper, used for validating hooks.
+type Hook struct {
+	*specs.Hook
+}
+
+// Validate a hook.
+func (h *Hook) Validate() error {
+	if _, ok := validHookNames[h.HookName]; !ok {
+		return fmt.Errorf("invalid hook name %q", h.HookName)
+	}
+	if h.Path == "" {
+		return fmt.Errorf("invalid hook %q with empty path", h.HookName)
+	}
+	if err := ValidateEnv(h.Env); err != nil {
+		return fmt.Errorf("invalid hook %q: %w", h.HookName, err)
+	}
+	return nil
+}
+
+// Mount is a CDI Mount wrapper, used for validating mounts.
+type Mount struct {
+	*specs.Mount
+}
+
+// Validate a mount.
+func (m *Mount) Validate() error {
+	if m.HostPath == "" {
+		return errors.New("invalid mount, empty host path")
+	}
+	if m.ContainerPath == "" {
+		return errors.New("invalid mount, empty container path")
+	}
+	return nil
+}
+
+// mergeEnv merges environment variables, overriding existing ones with
+// the same name.
+func mergeEnv(env []string, add []string) []string {
+	for _, v := range add {
+		name := strings.SplitN(v, "=", 2)[0]
+		replaced := false
+		for i, old := range env {
+			if strings.SplitN(old, "=", 2)[0] == name {
+				env[i] = v
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			env = append(env, v)
+		}
+	}
+	return env
+}
+
+// Ensure OCI Spec process is not nil so we can add environment variables.
+func ensureOCIProcess(spec *oci.Spec) {
+	if spec.Process == nil {
+		spec.Process = &oci.Process{}
+	}
+}
+
+// Ensure OCI Spec Linux section is not nil so we can add devices.
+func ensureOCILinux(spec *oci.Spec) {
+	if spec.Linux == nil {
+		spec.Linux = &oci.Linux{}
+	}
+}
+
+// Ensure OCI Spec hooks are not nil so we can add hooks.
+func ensureOCIHooks(spec *oci.Spec) {
+	if spec.Hooks == nil {
+		spec.Hooks = &oci.Hooks{}
+	}
+}
+
+// removeOCIDevice removes the device with the given path from the OCI Spec.
+func removeOCIDevice(spec *oci.Spec, path string) {
+	devices := spec.Linux.Devices[:0]
+	for _, d := range spec.Linux.Devices {
+		if d.Path != path {
+			devices = append(devices, d)
+		}
+	}
+	spec.Linux.Devices = devices
+}
+
+// removeOCIMount removes the mount with the given destination from the OCI Spec.
+func removeOCIMount(spec *oci.Spec, destination string) {
+	var mounts []oci.Mount
+	for _, m := range spec.Mounts {
+		if m.Destination != destination {
+			mounts = append(mounts, m)
+		}
+	}
+	spec.Mounts = mounts
+}
+
+// orderedMounts defines how to sort an OCI Spec Mount slice.
+// This is the almost the same implementation sa used by CRI-O and Docker,
+// with a minor tweak for stable sorting order (easier to test):
+//
+//	https://github.com/moby/moby/blob/17.05.x/daemon/volumes.go#L26
+type orderedMounts []oci.Mount
+
+// Len returns the number of mounts. Used in sorting.
+func (m orderedMounts) Len() int {
+	return len(m)
+}
+
+// Less returns true if the number of parts (a/b/c would be 3 parts) in the
+// mount indexed by parameter 1 is less than that of the mount indexed by
+// parameter 2. Used in sorting.
+func (m orderedMounts) Less(i, j int) bool {
+	ip, jp := m.parts(i), m.parts(j)
+	if ip < jp {
+		return true
+	}
+	if jp < ip {
+		return false
+	}
+	return m[i].Destination < m[j].Destination
+}
+
+// Swap swaps two items in an array of mounts. Used in sorting
+func (m orderedMounts) Swap(i, j int) {
+	m[i], m[j] = m[j], m[i]
+}
+
+// parts returns the number of parts in the destination of a mount. Used in sorting.
+func (m orderedMounts) parts(i int) int {
+	return strings.Count(filepath.Clean(m[i].Destination), string(os.PathSeparator))
+}
@@ -0,0 +1,68 @@
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// fillMissingInfo fills in missing mandatory attributes from the host device.
+func (d *DeviceNode) fillMissingInfo() error {
+	if d.HostPath == "" {
+		d.HostPath = d.Path
+	}
+
+	if d.Type != "" && (d.Major != 0 || d.Type == "p") {
+		return nil
+	}
+
+	hostType, major, minor, err := hostDeviceInfo(d.HostPath)
+	if err != nil {
+		return fmt.Errorf("failed to stat CDI host device %q: %w", d.HostPath, err)
+	}
+
+	if d.Type == "" {
+		d.Type = hostType
+	} else if d.Type != hostType {
+		return fmt.Errorf("CDI device (%q, %q), host type mismatch (%s, %s)",
+			d.Path, d.HostPath, d.Type, hostType)
+	}
+	if d.Major == 0 && d.Type != "p" {
+		d.Major = major
+		d.Minor = minor
+	}
+
+	return nil
+}
+
+// hostDeviceInfo returns the type, major and minor number of a host
+// device node. The type is one of "b", "c" or "p", as used by the OCI
+// runtime Spec.
+func hostDeviceInfo(path string) (string, int64, int64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", 0, 0, err
+	}
+
+	var devType string
+	switch mode := info.Mode(); {
+	case mode&os.ModeDevice == 0 && mode&os.ModeNamedPipe != 0:
+		return "p", 0, 0, nil
+	case mode&os.ModeDevice == 0:
+		return "", 0, 0, fmt.Errorf("%q is not a device node", path)
+	case mode&os.ModeCharDevice != 0:
+		devType = "c"
+	default:
+		devType = "b"
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", 0, 0, fmt.Errorf("failed to get device numbers of %q", path)
+	}
+	rdev := uint64(st.Rdev)
+
+	return devType, int64(unix.Major(rdev)), int64(unix.Minor(rdev)), nil
+}
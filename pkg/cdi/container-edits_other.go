@@ -0,0 +1,23 @@
+//go:build !linux
+// +build !linux
+
+package cdi
+
+import "fmt"
+
+// fillMissingInfo fills in missing mandatory attributes from the host device.
+func (d *DeviceNode) fillMissingInfo() error {
+	if d.HostPath == "" {
+		d.HostPath = d.Path
+	}
+	if d.Type != "" && (d.Major != 0 || d.Type == "p") {
+		return nil
+	}
+	return fmt.Errorf("failed to stat CDI host device %q: unsupported platform", d.HostPath)
+}
+
+// hostDeviceInfo returns the type, major and minor number of a host
+// device node.
+func hostDeviceInfo(path string) (string, int64, int64, error) {
+	return "", 0, 0, fmt.Errorf("failed to stat %q: unsupported platform", path)
+}
@@ -2,17 +2,21 @@ package cdi
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
-	"github.com/container-orchestrated-devices/container-device-interface/internal/validation" // to be changed
-	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"          //
-	cdi "github.com/container-orchestrated-devices/container-device-interface/specs-go"        //
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
 	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/internal/validation"
+	cdi "container-device-interface-aaron/specs-go"
 )
 
 // Device represents a CDI device of a Spec.
 type Device struct {
 	*cdi.Device
-	spec *Spec
+	spec     *Spec
+	resolved *ContainerEdits // edits of alias and composite devices
 }
 
 // Create a new Device, associate it with the given Spec.
@@ -46,8 +50,68 @@ func (d *Device) ApplyEdits(ociSpec *oci.Spec) error {
 	return d.edits().Apply(ociSpec)
 }
 
+// GetContainerEdits returns the container edits injected for this
+// device. For alias and composite devices these are the resolved edits
+// of the referenced devices.
+func (d *Device) GetContainerEdits() *ContainerEdits {
+	return d.edits()
+}
+
+// IsAlias checks if this device is an alias of another device.
+func (d *Device) IsAlias() bool {
+	return d.AliasOf != ""
+}
+
+// IsComposite checks if this device is a composite of other devices.
+func (d *Device) IsComposite() bool {
+	return len(d.Members) > 0
+}
+
+// references returns the devices an alias or a composite device refers
+// to, which are defined in the same Spec.
+func (d *Device) references() []*Device {
+	refs := d.Members
+	if d.IsAlias() {
+		refs = []string{d.AliasOf}
+	}
+	var devices []*Device
+	for _, name := range refs {
+		if ref := d.spec.GetDevice(name); ref != nil {
+			devices = append(devices, ref)
+		}
+	}
+	return devices
+}
+
+// GetRequires returns the qualified names of the devices which must be
+// injected together with this device.
+func (d *Device) GetRequires() []string {
+	return d.qualify(d.Requires)
+}
+
+// GetConflicts returns the qualified names of the devices which must
+// never be injected together with this device.
+func (d *Device) GetConflicts() []string {
+	return d.qualify(d.Conflicts)
+}
+
+// qualify qualifies device names relative to the Spec of this device.
+func (d *Device) qualify(names []string) []string {
+	var qualified []string
+	for _, name := range names {
+		if !parser.IsQualifiedName(name) {
+			name = parser.QualifiedName(d.spec.GetVendor(), d.spec.GetClass(), name)
+		}
+		qualified = append(qualified, name)
+	}
+	return qualified
+}
+
 // edits returns the applicable container edits for this spec.
 func (d *Device) edits() *ContainerEdits {
+	if d.resolved != nil {
+		return d.resolved
+	}
 	return &ContainerEdits{&d.ContainerEdits}
 }
 
@@ -63,12 +127,136 @@ func (d *Device) validate() error {
 	if err := validation.ValidateSpecAnnotations(name, d.Annotations); err != nil {
 		return err
 	}
-	edits := d.edits()
-	if edits.isEmpty() {
+	edits := &ContainerEdits{&d.ContainerEdits}
+	switch {
+	case d.IsAlias() && d.IsComposite():
+		return fmt.Errorf("invalid device %q, both alias and composite", d.Name)
+	case d.IsAlias() && !edits.isEmpty():
+		return fmt.Errorf("invalid alias device %q, non-empty device edits", d.Name)
+	case !d.IsAlias() && !d.IsComposite() && edits.isEmpty():
 		return fmt.Errorf("invalid device, empty device edits")
 	}
 	if err := edits.Validate(); err != nil {
 		return fmt.Errorf("invalid device %q: %w", d.Name, err)
 	}
+	if err := d.validateRelations(); err != nil {
+		return fmt.Errorf("invalid device %q: %w", d.Name, err)
+	}
+	return nil
+}
+
+// validateRelations validates the required and conflicting devices.
+func (d *Device) validateRelations() error {
+	requires := map[string]struct{}{}
+	for _, name := range d.Requires {
+		if err := validateRelation(d.Name, name); err != nil {
+			return fmt.Errorf("invalid required device: %w", err)
+		}
+		requires[name] = struct{}{}
+	}
+	for _, name := range d.Conflicts {
+		if err := validateRelation(d.Name, name); err != nil {
+			return fmt.Errorf("invalid conflicting device: %w", err)
+		}
+		if _, ok := requires[name]; ok {
+			return fmt.Errorf("device %q both required and conflicting", name)
+		}
+	}
+	return nil
+}
+
+// validateRelation validates a device name referenced by device dev.
+func validateRelation(dev, name string) error {
+	if !parser.IsQualifiedName(name) {
+		if name == dev {
+			return fmt.Errorf("reference to itself")
+		}
+		return ValidateDeviceName(name)
+	}
+	_, _, _, err := parser.ParseQualifiedName(name)
+	return err
+}
+
+// checkRelations checks that the unqualified required and conflicting
+// devices of a Spec refer to devices defined in the same Spec.
+func checkRelations(devices map[string]*Device) error {
+	var names []string
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := devices[name]
+		for _, ref := range append(append([]string{}, d.Requires...), d.Conflicts...) {
+			if parser.IsQualifiedName(ref) {
+				continue
+			}
+			if _, ok := devices[ref]; !ok {
+				return fmt.Errorf("invalid device %q, unknown device %q", name, ref)
+			}
+		}
+	}
+	return nil
+}
+
+// resolveDevices resolves the edits of the alias and composite devices
+// of a Spec. An alias gets the edits of the aliased device, a composite
+// gets the edits of all its members followed by its own, without
+// duplicate entries. References must be to devices of the same Spec and
+// must not form a cycle.
+func resolveDevices(devices map[string]*Device) error {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := map[string]int{}
+
+	var resolve func(d *Device, chain []string) error
+	resolve = func(d *Device, chain []string) error {
+		chain = append(chain, d.Name)
+		switch state[d.Name] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("invalid device %q, reference cycle %s",
+				chain[0], strings.Join(chain, " -> "))
+		}
+		state[d.Name] = visiting
+
+		refs := d.Members
+		if d.IsAlias() {
+			refs = []string{d.AliasOf}
+		}
+		if len(refs) > 0 {
+			edits := &ContainerEdits{&cdi.ContainerEdits{}}
+			for _, name := range refs {
+				ref, ok := devices[name]
+				if !ok {
+					return fmt.Errorf("invalid device %q, unknown device %q", d.Name, name)
+				}
+				if err := resolve(ref, chain); err != nil {
+					return err
+				}
+				edits.Append(ref.edits())
+			}
+			edits.Append(&ContainerEdits{&d.ContainerEdits})
+			d.resolved = edits.dedup()
+		}
+
+		state[d.Name] = done
+		return nil
+	}
+
+	var names []string
+	for name := range devices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if err := resolve(devices[name], nil); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
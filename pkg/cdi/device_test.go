@@ -0,0 +1,318 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAliasAndCompositeDevices(t *testing.T) {
+	const specHead = `cdiVersion: "0.7.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu0
+    containerEdits:
+      env: ["VENDOR_GPU=1"]
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+  - name: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU=1"]
+      deviceNodes:
+        - path: /dev/vendor1
+          type: c
+          major: 195
+          minor: 1
+`
+	testCases := []struct {
+		name    string
+		devices string
+		inject  []string
+		env     []string
+		nodes   []string
+		invalid bool
+	}{
+		{
+			name: "alias",
+			devices: `  - name: primary
+    aliasOf: gpu1
+`,
+			inject: []string{"vendor.com/gpu=primary"},
+			env:    []string{"VENDOR_GPU=1"},
+			nodes:  []string{"/dev/vendor1"},
+		},
+		{
+			name: "composite of composite and alias",
+			devices: `  - name: primary
+    aliasOf: gpu0
+  - name: all
+    members: [primary, gpu1]
+    containerEdits:
+      env: ["VENDOR_ALL=1"]
+  - name: everything
+    members: [all, gpu0]
+`,
+			inject: []string{"vendor.com/gpu=everything", "vendor.com/gpu=gpu1"},
+			env:    []string{"VENDOR_GPU=1", "VENDOR_ALL=1"},
+			nodes:  []string{"/dev/vendor0", "/dev/vendor1"},
+		},
+		{
+			name: "unknown member",
+			devices: `  - name: all
+    members: [gpu0, gpu2]
+`,
+			invalid: true,
+		},
+		{
+			name: "cycle",
+			devices: `  - name: a
+    members: [gpu0, b]
+  - name: b
+    aliasOf: c
+  - name: c
+    members: [a]
+`,
+			invalid: true,
+		},
+		{
+			name: "alias with edits",
+			devices: `  - name: primary
+    aliasOf: gpu0
+    containerEdits:
+      env: ["PRIMARY=1"]
+`,
+			invalid: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "vendor.yaml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(specHead+tc.devices), 0o644))
+
+			cache, err := NewCache(WithSpecDirs(dir))
+			require.NoError(t, err)
+			if tc.invalid {
+				require.Len(t, cache.GetErrors()[path], 1)
+				require.Empty(t, cache.ListDevices())
+				return
+			}
+
+			spec := &oci.Spec{}
+			_, err = cache.InjectDevices(spec, tc.inject...)
+			require.NoError(t, err)
+			require.Equal(t, tc.env, spec.Process.Env)
+
+			var nodes []string
+			for _, d := range spec.Linux.Devices {
+				nodes = append(nodes, d.Path)
+			}
+			require.Equal(t, tc.nodes, nodes)
+			require.Len(t, spec.Linux.Resources.Devices, len(tc.nodes))
+		})
+	}
+}
+
+func TestDeviceRelations(t *testing.T) {
+	const (
+		gpuSpec = `cdiVersion: "0.7.0"
+kind: vendor.com/gpu
+devices:
+  - name: ctl
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendorctl
+          type: c
+          major: 195
+  - name: gpu0
+    requires: [ctl]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+  - name: gpu1
+    requires: [ctl, vendor.com/fw=blob]
+    conflicts: [gpu0]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor1
+          type: c
+          major: 195
+`
+		fwSpec = `cdiVersion: "0.7.0"
+kind: vendor.com/fw
+devices:
+  - name: blob
+    requires: [vendor.com/gpu=ctl]
+    containerEdits:
+      env: ["VENDOR_FW=1"]
+`
+		refSpec = `cdiVersion: "0.7.0"
+kind: vendor.com/gpu
+devices:
+  - name: ctl
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendorctl
+          type: c
+          major: 195
+  - name: gpu0
+    requires: [ctl]
+    conflicts: [gpu1]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+  - name: gpu1
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor1
+          type: c
+          major: 195
+  - name: first
+    aliasOf: gpu0
+  - name: solo
+    members: [gpu0]
+  - name: all
+    members: [gpu0, gpu1]
+`
+	)
+
+	testCases := []struct {
+		name   string
+		specs  map[string]string
+		inject []string
+		nodes  []string
+		env    []string
+		errors int
+		fail   bool
+	}{
+		{
+			name:   "required device",
+			specs:  map[string]string{"gpu.yaml": gpuSpec, "fw.yaml": fwSpec},
+			inject: []string{"vendor.com/gpu=gpu0"},
+			nodes:  []string{"/dev/vendorctl", "/dev/vendor0"},
+		},
+		{
+			name:   "transitive and cross-Spec required devices",
+			specs:  map[string]string{"gpu.yaml": gpuSpec, "fw.yaml": fwSpec},
+			inject: []string{"vendor.com/gpu=gpu1"},
+			nodes:  []string{"/dev/vendorctl", "/dev/vendor1"},
+			env:    []string{"VENDOR_FW=1"},
+		},
+		{
+			name:   "conflicting devices",
+			specs:  map[string]string{"gpu.yaml": gpuSpec, "fw.yaml": fwSpec},
+			inject: []string{"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1"},
+			fail:   true,
+		},
+		{
+			name:   "device required by aliased device",
+			specs:  map[string]string{"gpu.yaml": refSpec},
+			inject: []string{"vendor.com/gpu=first"},
+			nodes:  []string{"/dev/vendorctl", "/dev/vendor0"},
+		},
+		{
+			name:   "device required by composite member",
+			specs:  map[string]string{"gpu.yaml": refSpec},
+			inject: []string{"vendor.com/gpu=solo"},
+			nodes:  []string{"/dev/vendorctl", "/dev/vendor0"},
+		},
+		{
+			name:   "device conflicting with aliased device",
+			specs:  map[string]string{"gpu.yaml": refSpec},
+			inject: []string{"vendor.com/gpu=first", "vendor.com/gpu=gpu1"},
+			fail:   true,
+		},
+		{
+			name:   "conflicting composite members",
+			specs:  map[string]string{"gpu.yaml": refSpec},
+			inject: []string{"vendor.com/gpu=all"},
+			fail:   true,
+		},
+		{
+			name:   "unresolvable required device",
+			specs:  map[string]string{"gpu.yaml": gpuSpec},
+			inject: []string{"vendor.com/gpu=gpu1"},
+			fail:   true,
+		},
+		{
+			name: "unknown unqualified reference",
+			specs: map[string]string{"gpu.yaml": `cdiVersion: "0.7.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu0
+    requires: [ctl]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+`},
+			errors: 1,
+		},
+		{
+			name: "required and conflicting device",
+			specs: map[string]string{"gpu.yaml": `cdiVersion: "0.7.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu0
+    requires: [gpu1]
+    conflicts: [gpu1]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+  - name: gpu1
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor1
+          type: c
+          major: 195
+`},
+			errors: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for name, data := range tc.specs {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+			}
+
+			cache, err := NewCache(WithSpecDirs(dir))
+			require.NoError(t, err)
+			if tc.errors > 0 {
+				require.Len(t, cache.GetErrors()[filepath.Join(dir, "gpu.yaml")], tc.errors)
+				return
+			}
+
+			spec := &oci.Spec{}
+			_, err = cache.InjectDevices(spec, tc.inject...)
+			if tc.fail {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var nodes []string
+			for _, d := range spec.Linux.Devices {
+				nodes = append(nodes, d.Path)
+			}
+			require.ElementsMatch(t, tc.nodes, nodes)
+			if tc.env != nil {
+				require.Equal(t, tc.env, spec.Process.Env)
+			}
+		})
+	}
+}
@@ -0,0 +1,334 @@
+package cdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+// DiffStatus tells how an entry differs between two Specs.
+type DiffStatus string
+
+const (
+	// DiffAdded marks an entry only present in the new Spec.
+	DiffAdded DiffStatus = "added"
+	// DiffRemoved marks an entry only present in the old Spec.
+	DiffRemoved DiffStatus = "removed"
+	// DiffChanged marks an entry present in both Specs with a different value.
+	DiffChanged DiffStatus = "changed"
+)
+
+// SpecDiff is the semantic difference between two versions of a CDI Spec.
+type SpecDiff struct {
+	// Kind is the kind of the Spec.
+	Kind string `json:"kind"`
+	// Status tells if the whole Spec was added, removed or changed.
+	Status DiffStatus `json:"status"`
+	// Changes are the changes to Spec-level fields.
+	Changes []*Change `json:"changes,omitempty"`
+	// Devices are the added, removed and changed devices, by name.
+	Devices []*DeviceDiff `json:"devices,omitempty"`
+}
+
+// DeviceDiff is the semantic difference between two versions of a device.
+type DeviceDiff struct {
+	Name    string     `json:"name"`
+	Status  DiffStatus `json:"status"`
+	Changes []*Change  `json:"changes,omitempty"`
+}
+
+// Change is a single changed entry of a Spec or device. Entries are
+// identified by their field and key: the variable name for env, the
+// container path for device nodes and mounts, the annotation key for
+// annotations. Hooks have no identity besides their full value.
+type Change struct {
+	Status DiffStatus `json:"status"`
+	Field  string     `json:"field"`
+	Key    string     `json:"key,omitempty"`
+	Old    string     `json:"old,omitempty"`
+	New    string     `json:"new,omitempty"`
+}
+
+// IsEmpty checks if the diff has no changes.
+func (d *SpecDiff) IsEmpty() bool {
+	return d.Status == DiffChanged && len(d.Changes) == 0 && len(d.Devices) == 0
+}
+
+// DiffSpecs returns the semantic difference between two raw CDI Specs.
+// Formatting and the order of devices, annotations and container edits
+// are ignored. A nil from or to Spec yields an added or removed Spec.
+func DiffSpecs(from, to *cdi.Spec) *SpecDiff {
+	switch {
+	case from == nil && to == nil:
+		return nil
+	case from == nil:
+		return &SpecDiff{Kind: to.Kind, Status: DiffAdded}
+	case to == nil:
+		return &SpecDiff{Kind: from.Kind, Status: DiffRemoved}
+	}
+
+	d := &SpecDiff{
+		Kind:   to.Kind,
+		Status: DiffChanged,
+	}
+	if from.Kind != to.Kind {
+		d.Changes = append(d.Changes, &Change{
+			Status: DiffChanged, Field: "kind", Old: from.Kind, New: to.Kind,
+		})
+	}
+	if from.Version != to.Version {
+		d.Changes = append(d.Changes, &Change{
+			Status: DiffChanged, Field: "cdiVersion", Old: from.Version, New: to.Version,
+		})
+	}
+	d.Changes = append(d.Changes, diffEntries("annotations", from.Annotations, to.Annotations)...)
+	d.Changes = append(d.Changes, diffEdits(&from.ContainerEdits, &to.ContainerEdits)...)
+
+	oldDevs, newDevs := map[string]*cdi.Device{}, map[string]*cdi.Device{}
+	for i := range from.Devices {
+		oldDevs[from.Devices[i].Name] = &from.Devices[i]
+	}
+	for i := range to.Devices {
+		newDevs[to.Devices[i].Name] = &to.Devices[i]
+	}
+
+	for _, name := range unionKeys(oldDevs, newDevs) {
+		o, n := oldDevs[name], newDevs[name]
+		switch {
+		case o == nil:
+			d.Devices = append(d.Devices, &DeviceDiff{Name: name, Status: DiffAdded})
+		case n == nil:
+			d.Devices = append(d.Devices, &DeviceDiff{Name: name, Status: DiffRemoved})
+		default:
+			var changes []*Change
+			if o.AliasOf != n.AliasOf {
+				changes = append(changes, &Change{
+					Status: DiffChanged, Field: "aliasOf", Old: o.AliasOf, New: n.AliasOf,
+				})
+			}
+			if om, nm := strings.Join(o.Members, ","), strings.Join(n.Members, ","); om != nm {
+				changes = append(changes, &Change{
+					Status: DiffChanged, Field: "members", Old: om, New: nm,
+				})
+			}
+			if or, nr := joinSet(o.Requires), joinSet(n.Requires); or != nr {
+				changes = append(changes, &Change{
+					Status: DiffChanged, Field: "requires", Old: or, New: nr,
+				})
+			}
+			if oc, nc := joinSet(o.Conflicts), joinSet(n.Conflicts); oc != nc {
+				changes = append(changes, &Change{
+					Status: DiffChanged, Field: "conflicts", Old: oc, New: nc,
+				})
+			}
+			changes = append(changes, diffEntries("annotations", o.Annotations, n.Annotations)...)
+			changes = append(changes, diffEdits(&o.ContainerEdits, &n.ContainerEdits)...)
+			if len(changes) > 0 {
+				d.Devices = append(d.Devices, &DeviceDiff{Name: name, Status: DiffChanged, Changes: changes})
+			}
+		}
+	}
+
+	return d
+}
+
+// DiffSpecSets returns the semantic differences between two sets of raw
+// CDI Specs, such as the contents of two Spec directories. Specs are
+// matched by kind and devices by name, so devices moving between the
+// Spec files of a kind are not reported. Only Specs with differences
+// are returned, sorted by kind.
+func DiffSpecSets(from, to []*cdi.Spec) ([]*SpecDiff, error) {
+	fromSpecs, err := specsByKind(from)
+	if err != nil {
+		return nil, err
+	}
+	toSpecs, err := specsByKind(to)
+	if err != nil {
+		return nil, err
+	}
+
+	var diffs []*SpecDiff
+	for _, kind := range unionKeys(fromSpecs, toSpecs) {
+		if d := DiffSpecs(fromSpecs[kind], toSpecs[kind]); !d.IsEmpty() {
+			diffs = append(diffs, d)
+		}
+	}
+	return diffs, nil
+}
+
+// specsByKind maps raw Specs by kind. Multiple Specs of the same kind
+// are merged into one, with the highest version, the annotations and
+// Spec edits of all in order, and the devices of all. A device defined
+// by more than one Spec of a kind is an error.
+func specsByKind(raws []*cdi.Spec) (map[string]*cdi.Spec, error) {
+	var (
+		specs   = map[string]*cdi.Spec{}
+		devices = map[string]struct{}{}
+	)
+	for _, raw := range raws {
+		for _, d := range raw.Devices {
+			name := raw.Kind + "=" + d.Name
+			if _, ok := devices[name]; ok {
+				return nil, fmt.Errorf("CDI device %q defined by multiple Specs", name)
+			}
+			devices[name] = struct{}{}
+		}
+
+		merged, ok := specs[raw.Kind]
+		if !ok {
+			merged = &cdi.Spec{Version: raw.Version, Kind: raw.Kind}
+			specs[raw.Kind] = merged
+		}
+		if newVersion(raw.Version).IsGreaterThan(newVersion(merged.Version)) {
+			merged.Version = raw.Version
+		}
+		for k, v := range raw.Annotations {
+			if merged.Annotations == nil {
+				merged.Annotations = map[string]string{}
+			}
+			merged.Annotations[k] = v
+		}
+		(&ContainerEdits{&merged.ContainerEdits}).Append(&ContainerEdits{&raw.ContainerEdits})
+		merged.Devices = append(merged.Devices, raw.Devices...)
+	}
+	return specs, nil
+}
+
+// diffEdits returns the changes between two sets of container edits.
+// Entries with the same key are resolved the way injection does it,
+// the last one wins.
+func diffEdits(from, to *cdi.ContainerEdits) []*Change {
+	var changes []*Change
+
+	changes = append(changes, diffEntries("env", envEntries(from.Env), envEntries(to.Env))...)
+
+	oldNodes, newNodes := map[string]string{}, map[string]string{}
+	for _, dn := range from.DeviceNodes {
+		if dn != nil {
+			oldNodes[dn.Path] = encodeEntry(dn)
+		}
+	}
+	for _, dn := range to.DeviceNodes {
+		if dn != nil {
+			newNodes[dn.Path] = encodeEntry(dn)
+		}
+	}
+	changes = append(changes, diffEntries("deviceNodes", oldNodes, newNodes)...)
+
+	oldMounts, newMounts := map[string]string{}, map[string]string{}
+	for _, m := range from.Mounts {
+		if m != nil {
+			oldMounts[m.ContainerPath] = encodeEntry(m)
+		}
+	}
+	for _, m := range to.Mounts {
+		if m != nil {
+			newMounts[m.ContainerPath] = encodeEntry(m)
+		}
+	}
+	changes = append(changes, diffEntries("mounts", oldMounts, newMounts)...)
+
+	oldHooks, newHooks := map[string]string{}, map[string]string{}
+	for _, h := range from.Hooks {
+		if h != nil {
+			enc := encodeEntry(h)
+			oldHooks[enc] = enc
+		}
+	}
+	for _, h := range to.Hooks {
+		if h != nil {
+			enc := encodeEntry(h)
+			newHooks[enc] = enc
+		}
+	}
+	for _, c := range diffEntries("hooks", oldHooks, newHooks) {
+		c.Key = ""
+		changes = append(changes, c)
+	}
+
+	oldConds, newConds := map[string]string{}, map[string]string{}
+	for _, c := range from.Conditional {
+		if c != nil {
+			enc := encodeEntry(c)
+			oldConds[enc] = enc
+		}
+	}
+	for _, c := range to.Conditional {
+		if c != nil {
+			enc := encodeEntry(c)
+			newConds[enc] = enc
+		}
+	}
+	for _, c := range diffEntries("conditional", oldConds, newConds) {
+		c.Key = ""
+		changes = append(changes, c)
+	}
+
+	return changes
+}
+
+// diffEntries returns the changes between two sets of keyed entries.
+func diffEntries(field string, from, to map[string]string) []*Change {
+	var changes []*Change
+
+	for _, key := range unionKeys(from, to) {
+		o, inOld := from[key]
+		n, inNew := to[key]
+		switch {
+		case !inOld:
+			changes = append(changes, &Change{Status: DiffAdded, Field: field, Key: key, New: n})
+		case !inNew:
+			changes = append(changes, &Change{Status: DiffRemoved, Field: field, Key: key, Old: o})
+		case o != n:
+			changes = append(changes, &Change{Status: DiffChanged, Field: field, Key: key, Old: o, New: n})
+		}
+	}
+
+	return changes
+}
+
+// envEntries maps environment variables by name.
+func envEntries(env []string) map[string]string {
+	entries := map[string]string{}
+	for _, e := range env {
+		key := strings.SplitN(e, "=", 2)[0]
+		entries[key] = e
+	}
+	return entries
+}
+
+// joinSet joins the sorted, unique names of a set of device names.
+func joinSet(names []string) string {
+	set := map[string]struct{}{}
+	for _, name := range names {
+		set[name] = struct{}{}
+	}
+	return strings.Join(unionKeys(set, nil), ",")
+}
+
+// encodeEntry encodes a container edit entry as compact JSON.
+func encodeEntry(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%+v", v)
+	}
+	return string(data)
+}
+
+// unionKeys returns the sorted union of the keys of two maps.
+func unionKeys[T any](a, b map[string]T) []string {
+	var keys []string
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,152 @@
+package cdi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+func TestDiffSpecs(t *testing.T) {
+	from, err := ParseSpec([]byte(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+containerEdits:
+  env: ["VENDOR=1", "DRIVER=1"]
+devices:
+  - name: dev0
+    requires: ["dev1", "dev2"]
+    conflicts: ["dev3"]
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          major: 195
+      mounts:
+        - hostPath: /usr/lib/libvendor.so.1
+          containerPath: /usr/lib/libvendor.so
+  - name: dev1
+    containerEdits:
+      env: ["DEV=1"]
+`))
+	require.NoError(t, err)
+
+	to, err := ParseSpec([]byte(`cdiVersion: "0.6.0"
+kind: vendor.com/device
+annotations:
+  vendor.com/driver: "2"
+containerEdits:
+  env: ["DRIVER=2", "VENDOR=1"]
+devices:
+  - name: dev2
+    containerEdits:
+      env: ["DEV=2"]
+  - name: dev0
+    requires: ["dev2", "dev1", "dev2"]
+    conflicts: ["dev4", "dev3"]
+    containerEdits:
+      mounts:
+        - hostPath: /usr/lib/libvendor.so.2
+          containerPath: /usr/lib/libvendor.so
+      deviceNodes:
+        - path: /dev/vendor0
+          major: 195
+`))
+	require.NoError(t, err)
+
+	require.True(t, DiffSpecs(from, from).IsEmpty())
+
+	require.Equal(t, &SpecDiff{
+		Kind:   "vendor.com/device",
+		Status: DiffChanged,
+		Changes: []*Change{
+			{Status: DiffChanged, Field: "cdiVersion", Old: "0.5.0", New: "0.6.0"},
+			{Status: DiffAdded, Field: "annotations", Key: "vendor.com/driver", New: "2"},
+			{Status: DiffChanged, Field: "env", Key: "DRIVER", Old: "DRIVER=1", New: "DRIVER=2"},
+		},
+		Devices: []*DeviceDiff{
+			{
+				Name:   "dev0",
+				Status: DiffChanged,
+				Changes: []*Change{
+					{Status: DiffChanged, Field: "conflicts", Old: "dev3", New: "dev3,dev4"},
+					{
+						Status: DiffChanged,
+						Field:  "mounts",
+						Key:    "/usr/lib/libvendor.so",
+						Old:    `{"hostPath":"/usr/lib/libvendor.so.1","containerPath":"/usr/lib/libvendor.so"}`,
+						New:    `{"hostPath":"/usr/lib/libvendor.so.2","containerPath":"/usr/lib/libvendor.so"}`,
+					},
+				},
+			},
+			{Name: "dev1", Status: DiffRemoved},
+			{Name: "dev2", Status: DiffAdded},
+		},
+	}, DiffSpecs(from, to))
+
+	diffs, err := DiffSpecSets(nil, []*cdi.Spec{to})
+	require.NoError(t, err)
+	require.Equal(t, []*SpecDiff{{Kind: "vendor.com/device", Status: DiffAdded}}, diffs)
+}
+
+func TestDiffSpecSets(t *testing.T) {
+	parse := func(data string) *cdi.Spec {
+		raw, err := ParseSpec([]byte(data))
+		require.NoError(t, err)
+		return raw
+	}
+
+	old := []*cdi.Spec{
+		parse(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+containerEdits:
+  env: ["VENDOR=1"]
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["DEV=0"]
+  - name: dev1
+    containerEdits:
+      env: ["DEV=1"]
+`),
+	}
+	new := []*cdi.Spec{
+		parse(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+containerEdits:
+  env: ["VENDOR=1"]
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["DEV=0"]
+`),
+		parse(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev1
+    containerEdits:
+      env: ["DEV=1"]
+  - name: dev2
+    containerEdits:
+      env: ["DEV=2"]
+`),
+	}
+
+	diffs, err := DiffSpecSets(old, old)
+	require.NoError(t, err)
+	require.Empty(t, diffs)
+
+	diffs, err = DiffSpecSets(old, new)
+	require.NoError(t, err)
+	require.Equal(t, []*SpecDiff{
+		{
+			Kind:   "vendor.com/device",
+			Status: DiffChanged,
+			Devices: []*DeviceDiff{
+				{Name: "dev2", Status: DiffAdded},
+			},
+		},
+	}, diffs)
+
+	_, err = DiffSpecSets(old, append(new, new[1]))
+	require.Error(t, err)
+}
@@ -0,0 +1,330 @@
+package cdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// DropInDirSuffix is the suffix of CDI Spec drop-in directories. The
+	// drop-ins for a vendor and class are in the directory named
+	// GenerateSpecName(vendor, class) + DropInDirSuffix of any Spec
+	// directory.
+	DropInDirSuffix = ".d"
+
+	// PatchAdd adds the edits of a patch.
+	PatchAdd = "add"
+	// PatchReplace replaces existing edits with the ones of a patch.
+	PatchReplace = "replace"
+	// PatchDelete deletes existing edits matching the ones of a patch.
+	PatchDelete = "delete"
+)
+
+// DropIn is a CDI Spec drop-in. It patches the edits of all CDI Specs
+// of a vendor and class without modifying the Spec files.
+type DropIn struct {
+	// Patches are the patches to apply, in order.
+	Patches []*Patch `json:"patches"`
+
+	path string
+}
+
+// Patch adds, replaces or deletes Spec or device edits. Env variables
+// are matched by name, device nodes by path, mounts by container path
+// and hooks by name and path. Device patches only apply to the Specs
+// of the vendor and class which define the device.
+type Patch struct {
+	// Op is the patch operation, add, replace or delete.
+	Op string `json:"op"`
+	// Device is the device to patch, the Spec if omitted.
+	Device string `json:"device,omitempty"`
+	// ContainerEdits are the edits to add, replace or delete.
+	ContainerEdits cdi.ContainerEdits `json:"containerEdits"`
+}
+
+// ReadDropIn reads the given CDI Spec drop-in file.
+func ReadDropIn(path string) (*DropIn, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CDI Spec drop-in %q: %w", path, err)
+	}
+	return parseDropIn(path, data)
+}
+
+// parseDropIn parses the given drop-in data read from path.
+func parseDropIn(path string, data []byte) (*DropIn, error) {
+	dropIn := &DropIn{path: path}
+	if err := yaml.UnmarshalStrict(data, dropIn); err != nil {
+		return nil, fmt.Errorf("failed to parse CDI Spec drop-in %q: %w", path, err)
+	}
+	for i, p := range dropIn.Patches {
+		if p == nil {
+			return nil, fmt.Errorf("invalid CDI Spec drop-in %q: patch #%d: empty patch",
+				path, i)
+		}
+		switch p.Op {
+		case PatchAdd, PatchReplace, PatchDelete:
+		default:
+			return nil, fmt.Errorf("invalid CDI Spec drop-in %q: patch #%d: invalid op %q",
+				path, i, p.Op)
+		}
+		if err := checkNilEdits(&p.ContainerEdits); err != nil {
+			return nil, fmt.Errorf("invalid CDI Spec drop-in %q: patch #%d: %w",
+				path, i, err)
+		}
+	}
+
+	return dropIn, nil
+}
+
+// checkNilEdits checks that edits, including conditional ones, have no
+// nil entries.
+func checkNilEdits(e *cdi.ContainerEdits) error {
+	for _, d := range e.DeviceNodes {
+		if d == nil {
+			return fmt.Errorf("empty device node")
+		}
+	}
+	for _, m := range e.Mounts {
+		if m == nil {
+			return fmt.Errorf("empty mount")
+		}
+	}
+	for _, h := range e.Hooks {
+		if h == nil {
+			return fmt.Errorf("empty hook")
+		}
+	}
+	for _, c := range e.Conditional {
+		if c == nil {
+			return fmt.Errorf("empty conditional edits")
+		}
+		if err := checkNilEdits(&c.ContainerEdits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkDevices checks that every device patched by the drop-in is one
+// of the given devices, defined by the Specs of its vendor and class.
+func (d *DropIn) checkDevices(devices map[string]bool) error {
+	for i, p := range d.Patches {
+		if p.Device != "" && !devices[p.Device] {
+			return fmt.Errorf("CDI Spec drop-in %q: patch #%d: unknown device %q",
+				d.path, i, p.Device)
+		}
+	}
+	return nil
+}
+
+// GetDropIns returns the paths of the drop-ins applied to this Spec.
+func (s *Spec) GetDropIns() []string {
+	return s.dropIns
+}
+
+// findDropIns returns the drop-ins for the given vendor and class in
+// the given Spec directories, in lexical order of their file names.
+// Drop-ins with the same name are ordered by directory priority.
+func findDropIns(dirs []string, vendor, class string) []string {
+	var paths []string
+	for _, dir := range dirs {
+		dropInDir := filepath.Join(dir, GenerateSpecName(vendor, class)+DropInDirSuffix)
+		for _, ext := range []string{".yaml", ".json"} {
+			matches, _ := filepath.Glob(filepath.Join(dropInDir, "*"+ext))
+			paths = append(paths, matches...)
+		}
+	}
+	sort.SliceStable(paths, func(i, j int) bool {
+		return filepath.Base(paths[i]) < filepath.Base(paths[j])
+	})
+	return paths
+}
+
+// applyDropIns applies the given drop-ins to a Spec. Drop-ins which
+// fail to apply, or which would make the Spec invalid, are skipped and
+// passed to failed with their error. It returns the patched Spec.
+func applyDropIns(spec *Spec, dropIns []*DropIn, failed func(*DropIn, error)) *Spec {
+	for _, dropIn := range dropIns {
+		patched, err := spec.applyDropIn(dropIn)
+		if err != nil {
+			failed(dropIn, err)
+			continue
+		}
+		patched.dropIns = append(append([]string{}, spec.dropIns...), dropIn.path)
+		spec = patched
+	}
+	return spec
+}
+
+// applyDropIn returns a copy of the Spec with the given drop-in applied.
+func (s *Spec) applyDropIn(dropIn *DropIn) (*Spec, error) {
+	path := dropIn.path
+	raw, err := copySpec(s.Spec)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range dropIn.Patches {
+		if err := p.apply(raw); err != nil {
+			return nil, fmt.Errorf("failed to apply CDI Spec drop-in %q: patch #%d: %w",
+				path, i, err)
+		}
+	}
+
+	spec, err := newSpec(raw, s.path, s.priority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply CDI Spec drop-in %q: %w", path, err)
+	}
+	return spec, nil
+}
+
+// copySpec returns a deep copy of a raw CDI Spec.
+func copySpec(raw *cdi.Spec) (*cdi.Spec, error) {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy CDI Spec: %w", err)
+	}
+	c := &cdi.Spec{}
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("failed to copy CDI Spec: %w", err)
+	}
+	return c, nil
+}
+
+// apply applies the patch to a raw CDI Spec. Device patches for devices
+// not defined by the Spec are skipped.
+func (p *Patch) apply(raw *cdi.Spec) error {
+	edits := &raw.ContainerEdits
+	if p.Device != "" {
+		edits = nil
+		for i := range raw.Devices {
+			if raw.Devices[i].Name == p.Device {
+				edits = &raw.Devices[i].ContainerEdits
+				break
+			}
+		}
+		if edits == nil {
+			return nil
+		}
+	}
+
+	switch p.Op {
+	case PatchAdd:
+		(&ContainerEdits{edits}).Append(&ContainerEdits{&p.ContainerEdits})
+		return nil
+	case PatchReplace:
+		return replaceEdits(edits, &p.ContainerEdits)
+	case PatchDelete:
+		return deleteEdits(edits, &p.ContainerEdits)
+	}
+	return fmt.Errorf("invalid op %q", p.Op)
+}
+
+// replaceEdits replaces the matching entries of edits with the ones in
+// with. Every entry must match an existing one.
+func replaceEdits(edits, with *cdi.ContainerEdits) error {
+	for _, env := range with.Env {
+		i := indexOf(len(edits.Env), func(i int) bool { return envName(edits.Env[i]) == envName(env) })
+		if i < 0 {
+			return fmt.Errorf("no env variable %q to replace", envName(env))
+		}
+		edits.Env[i] = env
+	}
+	for _, d := range with.DeviceNodes {
+		i := indexOf(len(edits.DeviceNodes), func(i int) bool { return edits.DeviceNodes[i].Path == d.Path })
+		if i < 0 {
+			return fmt.Errorf("no device node %q to replace", d.Path)
+		}
+		edits.DeviceNodes[i] = d
+	}
+	for _, m := range with.Mounts {
+		i := indexOf(len(edits.Mounts), func(i int) bool { return edits.Mounts[i].ContainerPath == m.ContainerPath })
+		if i < 0 {
+			return fmt.Errorf("no mount %q to replace", m.ContainerPath)
+		}
+		edits.Mounts[i] = m
+	}
+	for _, h := range with.Hooks {
+		i := indexOf(len(edits.Hooks), func(i int) bool { return matchHook(edits.Hooks[i], h) })
+		if i < 0 {
+			return fmt.Errorf("no %s hook %q to replace", h.HookName, h.Path)
+		}
+		edits.Hooks[i] = h
+	}
+	if len(with.Conditional) > 0 {
+		return fmt.Errorf("conditional edits can't be replaced")
+	}
+	return nil
+}
+
+// deleteEdits deletes the entries of edits matching the ones in del.
+// Entries of del without a match are ignored. Env variables may be
+// given by name only.
+func deleteEdits(edits, del *cdi.ContainerEdits) error {
+	if len(del.Conditional) > 0 {
+		return fmt.Errorf("conditional edits can't be deleted")
+	}
+
+	var env []string
+	for _, e := range edits.Env {
+		if indexOf(len(del.Env), func(i int) bool { return envName(del.Env[i]) == envName(e) }) < 0 {
+			env = append(env, e)
+		}
+	}
+	edits.Env = env
+
+	var nodes []*cdi.DeviceNode
+	for _, d := range edits.DeviceNodes {
+		if indexOf(len(del.DeviceNodes), func(i int) bool { return del.DeviceNodes[i].Path == d.Path }) < 0 {
+			nodes = append(nodes, d)
+		}
+	}
+	edits.DeviceNodes = nodes
+
+	var mounts []*cdi.Mount
+	for _, m := range edits.Mounts {
+		if indexOf(len(del.Mounts), func(i int) bool { return del.Mounts[i].ContainerPath == m.ContainerPath }) < 0 {
+			mounts = append(mounts, m)
+		}
+	}
+	edits.Mounts = mounts
+
+	var hooks []*cdi.Hook
+	for _, h := range edits.Hooks {
+		if indexOf(len(del.Hooks), func(i int) bool { return matchHook(h, del.Hooks[i]) }) < 0 {
+			hooks = append(hooks, h)
+		}
+	}
+	edits.Hooks = hooks
+
+	return nil
+}
+
+// matchHook checks if hook h matches the patch hook p. An omitted hook
+// name in p matches any name.
+func matchHook(h, p *cdi.Hook) bool {
+	return h.Path == p.Path && (p.HookName == "" || h.HookName == p.HookName)
+}
+
+// envName returns the name of an env variable given as NAME=value or NAME.
+func envName(env string) string {
+	return strings.SplitN(env, "=", 2)[0]
+}
+
+// indexOf returns the index of the first of n entries matching, or -1.
+func indexOf(n int, match func(int) bool) int {
+	for i := 0; i < n; i++ {
+		if match(i) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,223 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDropIns(t *testing.T) {
+	const specData = `cdiVersion: "0.5.0"
+kind: vendor.com/gpu
+containerEdits:
+  env: ["VENDOR=1", "VENDOR_LOG=info"]
+  hooks:
+    - hookName: createContainer
+      path: /usr/bin/vendor-hook
+devices:
+  - name: gpu0
+    containerEdits:
+      env: ["VENDOR_GPU=0"]
+`
+	testCases := []struct {
+		name    string
+		specs   map[string]string
+		dropIns map[string]string
+		env     []string
+		hooks   int
+		applied []string
+		errors  int
+	}{
+		{
+			name:  "no drop-ins",
+			env:   []string{"VENDOR=1", "VENDOR_LOG=info", "VENDOR_GPU=0"},
+			hooks: 1,
+		},
+		{
+			name: "add, replace and delete in lexical order",
+			dropIns: map[string]string{
+				"20-debug.yaml": `patches:
+  - op: replace
+    containerEdits:
+      env: ["VENDOR_LOG=trace"]
+`,
+				"10-local.yaml": `patches:
+  - op: replace
+    containerEdits:
+      env: ["VENDOR_LOG=debug"]
+  - op: delete
+    containerEdits:
+      env: ["VENDOR"]
+      hooks:
+        - path: /usr/bin/vendor-hook
+  - op: add
+    device: gpu0
+    containerEdits:
+      env: ["VENDOR_GPU_MODE=compute"]
+`,
+			},
+			env:     []string{"VENDOR_LOG=trace", "VENDOR_GPU=0", "VENDOR_GPU_MODE=compute"},
+			applied: []string{"10-local.yaml", "20-debug.yaml"},
+		},
+		{
+			name: "device patches apply to the Spec defining the device",
+			specs: map[string]string{
+				"vendor-gpu1.yaml": `cdiVersion: "0.5.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU=1"]
+`,
+			},
+			dropIns: map[string]string{
+				"10-gpu1.yaml": `patches:
+  - op: replace
+    device: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU=one"]
+  - op: add
+    containerEdits:
+      env: ["VENDOR_PATCHED=1"]
+`,
+			},
+			env:     []string{"VENDOR=1", "VENDOR_LOG=info", "VENDOR_PATCHED=1", "VENDOR_GPU=0"},
+			hooks:   1,
+			applied: []string{"10-gpu1.yaml"},
+		},
+		{
+			name: "drop-in errors are recorded once",
+			specs: map[string]string{
+				"vendor-gpu1.yaml": `cdiVersion: "0.5.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU=1"]
+`,
+			},
+			dropIns: map[string]string{
+				"10-missing.yaml": `patches:
+  - op: replace
+    containerEdits:
+      env: ["VENDOR_MISSING=1"]
+`,
+			},
+			env:    []string{"VENDOR=1", "VENDOR_LOG=info", "VENDOR_GPU=0"},
+			hooks:  1,
+			errors: 1,
+		},
+		{
+			name: "conditional edits can't be replaced or deleted",
+			dropIns: map[string]string{
+				"10-delete.yaml": `patches:
+  - op: delete
+    containerEdits:
+      env: ["VENDOR"]
+      conditional:
+        - arch: [amd64, arm64]
+          containerEdits:
+            env: ["VENDOR_ARCH=1"]
+`,
+				"20-replace.yaml": `patches:
+  - op: replace
+    containerEdits:
+      conditional:
+        - arch: [amd64, arm64]
+          containerEdits:
+            env: ["VENDOR_ARCH=1"]
+`,
+			},
+			env:    []string{"VENDOR=1", "VENDOR_LOG=info", "VENDOR_GPU=0"},
+			hooks:  1,
+			errors: 2,
+		},
+		{
+			name: "invalid drop-ins are skipped",
+			dropIns: map[string]string{
+				"10-missing.yaml": `patches:
+  - op: replace
+    containerEdits:
+      env: ["VENDOR_MISSING=1"]
+`,
+				"20-device.yaml": `patches:
+  - op: add
+    device: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU=1"]
+  - op: add
+    containerEdits:
+      env: ["VENDOR_DEVICE=1"]
+`,
+				"30-invalid.yaml": `patches:
+  - op: add
+    containerEdits:
+      env: ["NOT_AN_ASSIGNMENT"]
+`,
+				"40-op.yaml": `patches:
+  - op: merge
+`,
+				"50-ok.json": `{"patches": [{"op": "add", "containerEdits": {"env": ["VENDOR_OK=1"]}}]}`,
+				"60-nil-patch.yaml": `patches:
+  - null
+`,
+				"70-nil-mount.yaml": `patches:
+  - op: delete
+    containerEdits:
+      mounts:
+        - null
+`,
+			},
+			env:     []string{"VENDOR=1", "VENDOR_LOG=info", "VENDOR_DEVICE=1", "VENDOR_OK=1", "VENDOR_GPU=0"},
+			hooks:   1,
+			applied: []string{"20-device.yaml", "50-ok.json"},
+			errors:  6,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			specPath := filepath.Join(dir, "vendor.yaml")
+			require.NoError(t, ioutil.WriteFile(specPath, []byte(specData), 0o644))
+			for name, data := range tc.specs {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+			}
+			dropInDir := filepath.Join(dir, "vendor.com-gpu"+DropInDirSuffix)
+			require.NoError(t, os.Mkdir(dropInDir, 0o755))
+			for name, data := range tc.dropIns {
+				require.NoError(t, ioutil.WriteFile(filepath.Join(dropInDir, name), []byte(data), 0o644))
+			}
+
+			cache, err := NewCache(WithSpecDirs(dir))
+			require.NoError(t, err)
+			errors := 0
+			for _, errs := range cache.GetErrors() {
+				errors += len(errs)
+			}
+			require.Equal(t, tc.errors, errors)
+
+			dev := cache.GetDevice("vendor.com/gpu=gpu0")
+			require.NotNil(t, dev)
+			var applied []string
+			for _, path := range dev.GetSpec().GetDropIns() {
+				applied = append(applied, filepath.Base(path))
+			}
+			require.Equal(t, tc.applied, applied)
+
+			spec := &oci.Spec{}
+			_, err = cache.InjectDevices(spec, "vendor.com/gpu=gpu0")
+			require.NoError(t, err)
+			require.Equal(t, tc.env, spec.Process.Env)
+			if tc.hooks == 0 {
+				require.Nil(t, spec.Hooks)
+			} else {
+				require.Len(t, spec.Hooks.CreateContainer, tc.hooks)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+// CheckHost checks that the host paths referenced by the container edits
+// are consistent with the host. Device nodes must exist and match their
+// declared type and device numbers, mount sources must exist and hooks
+// must be executable. Conditional edits are only checked if their
+// conditions hold on the host. CheckHost returns an error for each
+// inconsistency.
+func (e *ContainerEdits) CheckHost() []error {
+	return e.checkHost(HostConditions)
+}
+
+// checkHost checks the host consistency of the edits, evaluating the
+// conditions of conditional edits with the given evaluator.
+func (e *ContainerEdits) checkHost(evaluator ConditionEvaluator) []error {
+	if e == nil || e.ContainerEdits == nil {
+		return nil
+	}
+
+	e, err := e.Evaluate(evaluator)
+	if err != nil {
+		return []error{err}
+	}
+
+	var errs []error
+	for _, d := range e.DeviceNodes {
+		if d == nil {
+			continue
+		}
+		if err := (&DeviceNode{d}).checkHost(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, m := range e.Mounts {
+		if m == nil || !filepath.IsAbs(m.HostPath) {
+			continue
+		}
+		if _, err := os.Stat(m.HostPath); err != nil {
+			errs = append(errs, fmt.Errorf("mount %q: %w", m.ContainerPath, err))
+		}
+	}
+	for _, h := range e.Hooks {
+		if h == nil {
+			continue
+		}
+		if err := checkHostExecutable(h.Path); err != nil {
+			errs = append(errs, fmt.Errorf("%s hook: %w", h.HookName, err))
+		}
+	}
+
+	return errs
+}
+
+// CheckHost checks the host consistency of every device in the cache,
+// including the Spec-level edits injected with the device. Conditions
+// of conditional edits are evaluated like during injection. It returns
+// the errors per qualified device name for broken devices.
+func (c *Cache) CheckHost() map[string][]error {
+	c.Lock()
+	defer c.Unlock()
+	c.refreshIfRequired()
+
+	specErrs := map[*Spec][]error{}
+	result := map[string][]error{}
+	for name, d := range c.devices {
+		spec := d.GetSpec()
+		errs, ok := specErrs[spec]
+		if !ok {
+			errs = spec.edits().checkHost(c.conditions)
+			specErrs[spec] = errs
+		}
+		errs = append(errs[:len(errs):len(errs)], d.edits().checkHost(c.conditions)...)
+		if len(errs) > 0 {
+			result[name] = errs
+		}
+	}
+
+	return result
+}
+
+// checkHost checks the device node against the host device.
+func (d *DeviceNode) checkHost() error {
+	path := d.HostPath
+	if path == "" {
+		path = d.Path
+	}
+
+	hostType, major, minor, err := hostDeviceInfo(path)
+	if err != nil {
+		return fmt.Errorf("device node %q: %w", d.Path, err)
+	}
+	if d.Type != "" && d.Type != hostType {
+		return fmt.Errorf("device node %q: type %q, host device %q has type %q",
+			d.Path, d.Type, path, hostType)
+	}
+	if d.Major != 0 && (d.Major != major || d.Minor != minor) {
+		return fmt.Errorf("device node %q: device %d:%d, host device %q is %d:%d",
+			d.Path, d.Major, d.Minor, path, major, minor)
+	}
+
+	return nil
+}
+
+// checkHostExecutable checks that the host path is an executable file.
+func checkHostExecutable(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%q is not an executable file", path)
+	}
+	return nil
+}
+
+// HostDeviceNode returns a raw CDI device node for the given host device
+// with its type and device numbers filled in from the host.
+func HostDeviceNode(path string) (*cdi.DeviceNode, error) {
+	hostType, major, minor, err := hostDeviceInfo(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat host device %q: %w", path, err)
+	}
+	return &cdi.DeviceNode{
+		Path:  path,
+		Type:  hostType,
+		Major: major,
+		Minor: minor,
+	}, nil
+}
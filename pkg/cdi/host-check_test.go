@@ -0,0 +1,110 @@
+//go:build linux
+// +build linux
+
+package cdi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+func TestCheckHost(t *testing.T) {
+	dir := t.TempDir()
+	hook := filepath.Join(dir, "hook")
+	require.NoError(t, ioutil.WriteFile(hook, []byte("#!/bin/sh\n"), 0o755))
+	notExec := filepath.Join(dir, "not-exec")
+	require.NoError(t, ioutil.WriteFile(notExec, []byte("#!/bin/sh\n"), 0o644))
+
+	testCases := []struct {
+		name   string
+		edits  cdi.ContainerEdits
+		errors int
+	}{
+		{
+			name: "consistent",
+			edits: cdi.ContainerEdits{
+				DeviceNodes: []*cdi.DeviceNode{
+					{Path: "/dev/null", Type: "c", Major: 1, Minor: 3},
+					{Path: "/dev/zero"},
+				},
+				Mounts: []*cdi.Mount{
+					{HostPath: dir, ContainerPath: "/opt/vendor"},
+					{HostPath: "tmpfs", ContainerPath: "/tmp", Type: "tmpfs"},
+				},
+				Hooks: []*cdi.Hook{
+					{HookName: "createContainer", Path: hook},
+				},
+			},
+		},
+		{
+			name: "missing device node",
+			edits: cdi.ContainerEdits{
+				DeviceNodes: []*cdi.DeviceNode{
+					{Path: "/dev/vendor0", HostPath: filepath.Join(dir, "vendor0")},
+				},
+			},
+			errors: 1,
+		},
+		{
+			name: "mismatching device node",
+			edits: cdi.ContainerEdits{
+				DeviceNodes: []*cdi.DeviceNode{
+					{Path: "/dev/null", Type: "b"},
+					{Path: "/dev/zero", Type: "c", Major: 1, Minor: 3},
+					{Path: "/dev/vendor0", HostPath: hook},
+				},
+			},
+			errors: 3,
+		},
+		{
+			name: "missing mount and broken hooks",
+			edits: cdi.ContainerEdits{
+				Mounts: []*cdi.Mount{
+					{HostPath: filepath.Join(dir, "lib"), ContainerPath: "/usr/lib/libvendor.so"},
+				},
+				Hooks: []*cdi.Hook{
+					{HookName: "createContainer", Path: notExec},
+					{HookName: "createContainer", Path: dir},
+					{HookName: "createContainer", Path: filepath.Join(dir, "missing")},
+				},
+			},
+			errors: 4,
+		},
+		{
+			name: "conditional edits",
+			edits: cdi.ContainerEdits{
+				Conditional: []*cdi.ConditionalEdits{
+					{
+						IfExists: dir,
+						ContainerEdits: cdi.ContainerEdits{
+							Hooks: []*cdi.Hook{
+								{HookName: "createContainer", Path: notExec},
+							},
+						},
+					},
+					{
+						IfExists: filepath.Join(dir, "missing"),
+						ContainerEdits: cdi.ContainerEdits{
+							Hooks: []*cdi.Hook{
+								{HookName: "createContainer", Path: filepath.Join(dir, "missing")},
+							},
+						},
+					},
+				},
+			},
+			errors: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			edits := &ContainerEdits{&tc.edits}
+			require.Len(t, edits.CheckHost(), tc.errors)
+		})
+	}
+}
@@ -0,0 +1,278 @@
+package cdi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// PolicyRuleHookPaths is the rule for allowed hook binaries.
+	PolicyRuleHookPaths = "hookPaths"
+	// PolicyRuleHostPaths is the rule for allowed mount and device node
+	// host path prefixes.
+	PolicyRuleHostPaths = "hostPathPrefixes"
+	// PolicyRuleRequiredMountOptions is the rule for required mount options.
+	PolicyRuleRequiredMountOptions = "requiredMountOptions"
+	// PolicyRuleForbiddenMountOptions is the rule for forbidden mount options.
+	PolicyRuleForbiddenMountOptions = "forbiddenMountOptions"
+	// PolicyRuleEnvKeys is the rule for allowed env variable names.
+	PolicyRuleEnvKeys = "envKeys"
+)
+
+// Policy restricts the container edits the CDI Specs of a vendor and
+// class may inject. An omitted allow-list allows anything, an empty one
+// allows nothing.
+type Policy struct {
+	// Kind is the vendor and class the policy applies to, for instance
+	// "vendor.com/gpu", "vendor.com/*" or "*" for all.
+	Kind string `json:"kind"`
+	// HookPaths are the allowed hook binaries.
+	HookPaths []string `json:"hookPaths,omitempty"`
+	// HostPathPrefixes are the allowed host path prefixes of mounts and
+	// device nodes.
+	HostPathPrefixes []string `json:"hostPathPrefixes,omitempty"`
+	// RequiredMountOptions are options every mount must have, like "ro".
+	RequiredMountOptions []string `json:"requiredMountOptions,omitempty"`
+	// ForbiddenMountOptions are options no mount may have, like "rshared".
+	ForbiddenMountOptions []string `json:"forbiddenMountOptions,omitempty"`
+	// EnvKeys are the allowed env variable names. A trailing '*' matches
+	// any suffix.
+	EnvKeys []string `json:"envKeys,omitempty"`
+}
+
+// PolicyViolation is a single violation of a policy rule.
+type PolicyViolation struct {
+	// Kind is the vendor and class of the violating Spec.
+	Kind string `json:"kind"`
+	// Device is the violating device, empty for Spec edits.
+	Device string `json:"device,omitempty"`
+	// Rule is the violated rule, one of the PolicyRule constants.
+	Rule string `json:"rule"`
+	// Value is the offending value.
+	Value string `json:"value"`
+}
+
+// Error returns the violation as an error string.
+func (v *PolicyViolation) Error() string {
+	where := v.Kind
+	if v.Device != "" {
+		where += "=" + v.Device
+	}
+	return fmt.Sprintf("%s: %s violates policy rule %s", where, v.Value, v.Rule)
+}
+
+// PolicyError lists the policy violations of container edits.
+type PolicyError struct {
+	Violations []*PolicyViolation
+}
+
+// Error returns all violations as a single error string.
+func (e *PolicyError) Error() string {
+	var msgs []string
+	for _, v := range e.Violations {
+		msgs = append(msgs, v.Error())
+	}
+	return "policy violation: " + strings.Join(msgs, ", ")
+}
+
+// WithPolicies returns an option to enforce the given policies, both
+// when loading Specs and when injecting devices. Specs violating any
+// of the policies for their vendor and class are not loaded.
+func WithPolicies(policies ...*Policy) Option {
+	return func(c *Cache) error {
+		for _, p := range policies {
+			if err := p.Validate(); err != nil {
+				return err
+			}
+		}
+		c.policies = policies
+		return nil
+	}
+}
+
+// Validate the policy.
+func (p *Policy) Validate() error {
+	if p.Kind != "*" {
+		vendor, class := parser.ParseQualifier(p.Kind)
+		if err := ValidateVendorName(vendor); err != nil {
+			return fmt.Errorf("invalid policy kind %q: %w", p.Kind, err)
+		}
+		if class != "*" {
+			if err := ValidateClassName(class); err != nil {
+				return fmt.Errorf("invalid policy kind %q: %w", p.Kind, err)
+			}
+		}
+	}
+	for _, path := range append(append([]string{}, p.HookPaths...), p.HostPathPrefixes...) {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("invalid policy for %q, relative path %q", p.Kind, path)
+		}
+	}
+	return nil
+}
+
+// appliesTo checks if the policy applies to the given Spec kind.
+func (p *Policy) appliesTo(kind string) bool {
+	if p.Kind == "*" || p.Kind == kind {
+		return true
+	}
+	if vendor, class := parser.ParseQualifier(p.Kind); class == "*" {
+		v, _ := parser.ParseQualifier(kind)
+		return v == vendor
+	}
+	return false
+}
+
+// checkPolicies checks a Spec, including all its device, conditional
+// and drop-in edits, against the policies applying to it.
+func checkPolicies(policies []*Policy, spec *Spec) error {
+	var violations []*PolicyViolation
+	violations = append(violations, checkEditsPolicies(policies, spec.Kind, "", spec.edits())...)
+	for _, d := range spec.Devices {
+		violations = append(violations, checkEditsPolicies(policies, spec.Kind, d.Name,
+			&ContainerEdits{&d.ContainerEdits})...)
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// checkInjectionPolicies checks the edits of the given Specs and devices
+// about to be injected against the policies applying to them.
+func checkInjectionPolicies(policies []*Policy, specs []*Spec, devices []*Device) error {
+	if len(policies) == 0 {
+		return nil
+	}
+
+	var violations []*PolicyViolation
+	for _, s := range specs {
+		violations = append(violations, checkEditsPolicies(policies, s.Kind, "", s.edits())...)
+	}
+	for _, d := range devices {
+		violations = append(violations, checkEditsPolicies(policies, d.GetSpec().Kind, d.Name, d.edits())...)
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// checkEditsPolicies checks edits of the given Spec kind and device
+// against the policies applying to them.
+func checkEditsPolicies(policies []*Policy, kind, device string, e *ContainerEdits) []*PolicyViolation {
+	var violations []*PolicyViolation
+	for _, p := range policies {
+		if p.appliesTo(kind) {
+			for _, v := range p.check(e) {
+				v.Kind, v.Device = kind, device
+				violations = append(violations, v)
+			}
+		}
+	}
+	return violations
+}
+
+// check checks edits, including conditional ones, against the policy.
+func (p *Policy) check(e *ContainerEdits) []*PolicyViolation {
+	if e == nil || e.ContainerEdits == nil {
+		return nil
+	}
+
+	var violations []*PolicyViolation
+	violate := func(rule, value string) {
+		violations = append(violations, &PolicyViolation{Rule: rule, Value: value})
+	}
+
+	if p.EnvKeys != nil {
+		for _, env := range e.Env {
+			if name := envName(env); !matchAny(p.EnvKeys, name, matchEnvKey) {
+				violate(PolicyRuleEnvKeys, name)
+			}
+		}
+	}
+	if p.HostPathPrefixes != nil {
+		for _, d := range e.DeviceNodes {
+			path := d.HostPath
+			if path == "" {
+				path = d.Path
+			}
+			if !matchAny(p.HostPathPrefixes, path, matchPathPrefix) {
+				violate(PolicyRuleHostPaths, path)
+			}
+		}
+		for _, m := range e.Mounts {
+			if !matchAny(p.HostPathPrefixes, m.HostPath, matchPathPrefix) {
+				violate(PolicyRuleHostPaths, m.HostPath)
+			}
+		}
+	}
+	for _, m := range e.Mounts {
+		for _, opt := range p.RequiredMountOptions {
+			if !hasOption(m, opt) {
+				violate(PolicyRuleRequiredMountOptions, m.ContainerPath+": "+opt)
+			}
+		}
+		for _, opt := range p.ForbiddenMountOptions {
+			if hasOption(m, opt) {
+				violate(PolicyRuleForbiddenMountOptions, m.ContainerPath+": "+opt)
+			}
+		}
+	}
+	if p.HookPaths != nil {
+		for _, h := range e.Hooks {
+			if !matchAny(p.HookPaths, h.Path, func(allowed, path string) bool {
+				return filepath.Clean(allowed) == filepath.Clean(path)
+			}) {
+				violate(PolicyRuleHookPaths, h.Path)
+			}
+		}
+	}
+	for _, c := range e.Conditional {
+		violations = append(violations, p.check(&ContainerEdits{&c.ContainerEdits})...)
+	}
+
+	return violations
+}
+
+// matchAny checks if value matches any of the patterns.
+func matchAny(patterns []string, value string, match func(pattern, value string) bool) bool {
+	for _, pattern := range patterns {
+		if match(pattern, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchEnvKey checks if an env variable name matches an allowed key.
+func matchEnvKey(key, name string) bool {
+	if prefix := strings.TrimSuffix(key, "*"); prefix != key {
+		return strings.HasPrefix(name, prefix)
+	}
+	return key == name
+}
+
+// matchPathPrefix checks if path is prefix or a path below it.
+func matchPathPrefix(prefix, path string) bool {
+	prefix, path = filepath.Clean(prefix), filepath.Clean(path)
+	if prefix == path || prefix == "/" {
+		return true
+	}
+	return strings.HasPrefix(path, prefix+"/")
+}
+
+// hasOption checks if a mount has the given option.
+func hasOption(m *cdi.Mount, option string) bool {
+	for _, o := range m.Options {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
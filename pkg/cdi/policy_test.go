@@ -0,0 +1,148 @@
+package cdi
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicies(t *testing.T) {
+	const specData = `cdiVersion: "0.8.0"
+kind: vendor.com/gpu
+containerEdits:
+  env: ["VENDOR_VISIBLE=all"]
+  hooks:
+    - hookName: createContainer
+      path: /usr/bin/vendor-hook
+devices:
+  - name: gpu0
+    containerEdits:
+      env: ["LD_PRELOAD=/usr/lib/libvendor.so"]
+      deviceNodes:
+        - path: /dev/vendor0
+      mounts:
+        - hostPath: /usr/lib/vendor
+          containerPath: /usr/lib/vendor
+          options: [ro, rbind, rshared]
+      conditional:
+        - arch: [arm64]
+          containerEdits:
+            mounts:
+              - hostPath: /opt/vendor
+                containerPath: /opt/vendor
+                options: [ro]
+`
+	type violation struct {
+		device string
+		rule   string
+		value  string
+	}
+
+	for _, tc := range []struct {
+		name       string
+		policies   []*Policy
+		violations []violation
+		invalid    bool
+	}{
+		{
+			name: "all allowed",
+			policies: []*Policy{
+				{
+					Kind:             "vendor.com/gpu",
+					HookPaths:        []string{"/usr/bin/vendor-hook"},
+					HostPathPrefixes: []string{"/dev/vendor0", "/usr/lib/vendor", "/opt"},
+					EnvKeys:          []string{"VENDOR_*", "LD_PRELOAD"},
+				},
+				{
+					Kind:    "other.com/*",
+					EnvKeys: []string{},
+				},
+			},
+		},
+		{
+			name: "violations",
+			policies: []*Policy{
+				{
+					Kind:                  "vendor.com/*",
+					HookPaths:             []string{},
+					HostPathPrefixes:      []string{"/dev", "/usr/lib/vendor/lib"},
+					RequiredMountOptions:  []string{"ro"},
+					ForbiddenMountOptions: []string{"rshared"},
+					EnvKeys:               []string{"VENDOR_*"},
+				},
+			},
+			violations: []violation{
+				{"", PolicyRuleHookPaths, "/usr/bin/vendor-hook"},
+				{"gpu0", PolicyRuleEnvKeys, "LD_PRELOAD"},
+				{"gpu0", PolicyRuleHostPaths, "/usr/lib/vendor"},
+				{"gpu0", PolicyRuleForbiddenMountOptions, "/usr/lib/vendor: rshared"},
+				{"gpu0", PolicyRuleHostPaths, "/opt/vendor"},
+			},
+		},
+		{
+			name: "several policies",
+			policies: []*Policy{
+				{Kind: "*", ForbiddenMountOptions: []string{"rshared"}},
+				{Kind: "vendor.com/gpu", RequiredMountOptions: []string{"nosuid"}},
+			},
+			violations: []violation{
+				{"gpu0", PolicyRuleForbiddenMountOptions, "/usr/lib/vendor: rshared"},
+				{"gpu0", PolicyRuleRequiredMountOptions, "/usr/lib/vendor: nosuid"},
+				{"gpu0", PolicyRuleRequiredMountOptions, "/opt/vendor: nosuid"},
+			},
+		},
+		{
+			name:     "invalid kind",
+			policies: []*Policy{{Kind: "vendor.com"}},
+			invalid:  true,
+		},
+		{
+			name:     "relative path",
+			policies: []*Policy{{Kind: "*", HookPaths: []string{"bin/hook"}}},
+			invalid:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "vendor.yaml")
+			require.NoError(t, ioutil.WriteFile(path, []byte(specData), 0o644))
+
+			cache, err := NewCache(WithSpecDirs(dir), WithPolicies(tc.policies...))
+			if tc.invalid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			if len(tc.violations) == 0 {
+				require.Empty(t, cache.GetErrors())
+				require.NotNil(t, cache.GetDevice("vendor.com/gpu=gpu0"))
+				return
+			}
+
+			require.Nil(t, cache.GetDevice("vendor.com/gpu=gpu0"))
+			errs := cache.GetErrors()[path]
+			require.Len(t, errs, 1)
+
+			var policyErr *PolicyError
+			require.True(t, errors.As(errs[0], &policyErr))
+			var violations []violation
+			for _, v := range policyErr.Violations {
+				require.Equal(t, "vendor.com/gpu", v.Kind)
+				violations = append(violations, violation{v.Device, v.Rule, v.Value})
+			}
+			require.Equal(t, tc.violations, violations)
+
+			// the same policies are enforced when injecting
+			unchecked, err := NewCache(WithSpecDirs(dir))
+			require.NoError(t, err)
+			dev := unchecked.GetDevice("vendor.com/gpu=gpu0")
+			require.NotNil(t, dev)
+			err = checkInjectionPolicies(tc.policies, []*Spec{dev.GetSpec()}, []*Device{dev})
+			require.True(t, errors.As(err, &policyErr))
+		})
+	}
+}
@@ -0,0 +1,20 @@
+package cdi
+
+import (
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+)
+
+// ValidateVendorName checks the validity of a vendor name.
+func ValidateVendorName(vendor string) error {
+	return parser.ValidateVendorName(vendor)
+}
+
+// ValidateClassName checks the validity of a class name.
+func ValidateClassName(class string) error {
+	return parser.ValidateClassName(class)
+}
+
+// ValidateDeviceName checks the validity of a device name.
+func ValidateDeviceName(name string) error {
+	return parser.ValidateDeviceName(name)
+}
@@ -0,0 +1,227 @@
+package cdi
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/specs-go"
+)
+
+const (
+	// AllDevices is the device name requesting every device of a kind.
+	AllDevices = "all"
+)
+
+// DeviceRequest is a parsed Docker/Podman style --device request. It
+// either requests a CDI device by qualified name or a plain host device
+// by path.
+type DeviceRequest struct {
+	// Name is the qualified CDI device name. It is empty for host
+	// device requests.
+	Name string `json:"name,omitempty"`
+	// HostPath is the host device path of a host device request.
+	HostPath string `json:"hostPath,omitempty"`
+	// ContainerPath is the container device path of a host device request.
+	ContainerPath string `json:"containerPath,omitempty"`
+	// Permissions are the requested cgroup permissions. If empty, the
+	// permissions of the CDI device nodes, or "rwm" for host devices,
+	// are used.
+	Permissions string `json:"permissions,omitempty"`
+}
+
+// IsCDI checks if the request is for a CDI device.
+func (r *DeviceRequest) IsCDI() bool {
+	return r.Name != ""
+}
+
+// String returns the request in --device syntax.
+func (r *DeviceRequest) String() string {
+	s := r.Name
+	if !r.IsCDI() {
+		s = r.HostPath
+		if r.ContainerPath != r.HostPath {
+			s += ":" + r.ContainerPath
+		}
+	}
+	if r.Permissions != "" {
+		s += ":" + r.Permissions
+	}
+	return s
+}
+
+// ParseDeviceRequest parses a --device request. Requests starting with
+// a '/' are host device requests of the form "host[:container][:perms]".
+// Other requests are CDI requests of the form "vendor.com/class=name[:perms]",
+// where the device may also be given by a Selector.
+// perms is a combination of 'r', 'w' and 'm'. Since device names may
+// contain ':', a trailing ":perms" is always taken as permissions.
+func ParseDeviceRequest(request string) (*DeviceRequest, error) {
+	if request == "" {
+		return nil, fmt.Errorf("invalid device request, empty")
+	}
+
+	if !strings.HasPrefix(request, "/") {
+		r := &DeviceRequest{Name: request}
+		if i := strings.LastIndex(request, ":"); i >= 0 && isPermissions(request[i+1:]) {
+			r.Name, r.Permissions = request[:i], request[i+1:]
+		}
+		if IsSelector(r.Name) {
+			if _, err := ParseSelector(r.Name); err != nil {
+				return nil, fmt.Errorf("invalid device request %q: %w", request, err)
+			}
+			return r, nil
+		}
+		if _, _, _, err := parser.ParseQualifiedName(r.Name); err != nil {
+			return nil, fmt.Errorf("invalid device request %q: %w", request, err)
+		}
+		return r, nil
+	}
+
+	r := &DeviceRequest{}
+	parts := strings.Split(request, ":")
+	switch {
+	case len(parts) == 1:
+		r.HostPath, r.ContainerPath = parts[0], parts[0]
+	case len(parts) == 2 && isPermissions(parts[1]):
+		r.HostPath, r.ContainerPath, r.Permissions = parts[0], parts[0], parts[1]
+	case len(parts) == 2:
+		r.HostPath, r.ContainerPath = parts[0], parts[1]
+	case len(parts) == 3 && isPermissions(parts[2]):
+		r.HostPath, r.ContainerPath, r.Permissions = parts[0], parts[1], parts[2]
+	default:
+		return nil, fmt.Errorf("invalid device request %q", request)
+	}
+	if !filepath.IsAbs(r.ContainerPath) {
+		return nil, fmt.Errorf("invalid device request %q, container path %q is not absolute",
+			request, r.ContainerPath)
+	}
+	r.HostPath = filepath.Clean(r.HostPath)
+	r.ContainerPath = filepath.Clean(r.ContainerPath)
+
+	return r, nil
+}
+
+// ResolveDeviceRequests parses the given --device requests and returns
+// them normalized for injection. Selectors are expanded to the matching
+// devices, in sorted order. A CDI request for the "all" device of a kind
+// which does not define such a device is expanded to every device of
+// that kind. Requests for the same CDI device are
+// collapsed into the first one. Unresolvable CDI devices are an error.
+func (c *Cache) ResolveDeviceRequests(requests ...string) ([]*DeviceRequest, error) {
+	var (
+		result []*DeviceRequest
+		seen   = map[string]bool{}
+	)
+
+	add := func(r *DeviceRequest) {
+		if r.IsCDI() {
+			if seen[r.Name] {
+				return
+			}
+			seen[r.Name] = true
+		}
+		result = append(result, r)
+	}
+
+	for _, request := range requests {
+		r, err := ParseDeviceRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		if !r.IsCDI() || c.GetDevice(r.Name) != nil {
+			add(r)
+			continue
+		}
+
+		selector := r.Name
+		if !IsSelector(selector) {
+			vendor, class, name, _ := parser.ParseQualifiedName(r.Name)
+			if name != AllDevices {
+				return nil, fmt.Errorf("unresolvable CDI device %q", r.Name)
+			}
+			selector = vendor + "/" + class
+		}
+		devices, err := c.ResolveSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		if len(devices) == 0 {
+			return nil, fmt.Errorf("unresolvable CDI device %q, no matching devices", r.Name)
+		}
+		for _, device := range devices {
+			add(&DeviceRequest{Name: device, Permissions: r.Permissions})
+		}
+	}
+
+	return result, nil
+}
+
+// InjectDeviceRequests injects the devices of normalized requests, as
+// returned by ResolveDeviceRequests, to an OCI Spec. CDI devices are
+// injected like InjectDevices does, host devices are injected after
+// them as plain device nodes.
+func (c *Cache) InjectDeviceRequests(ociSpec *oci.Spec, requests []*DeviceRequest) error {
+	if ociSpec == nil {
+		return fmt.Errorf("can't inject devices, nil OCI Spec")
+	}
+
+	var (
+		devices []string
+		perms   = map[string]string{}
+		host    []*DeviceRequest
+	)
+	for _, r := range requests {
+		if !r.IsCDI() {
+			host = append(host, r)
+			continue
+		}
+		devices = append(devices, r.Name)
+		if r.Permissions != "" {
+			perms[r.Name] = r.Permissions
+		}
+	}
+
+	c.Lock()
+	c.refreshIfRequired()
+	_, err := c.injectDevices(ociSpec, devices, perms)
+	c.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range host {
+		node, err := HostDeviceNode(r.HostPath)
+		if err != nil {
+			return fmt.Errorf("failed to inject device %q: %w", r, err)
+		}
+		node.HostPath = r.HostPath
+		node.Path = r.ContainerPath
+		node.Permissions = r.Permissions
+		edits := &ContainerEdits{&specs.ContainerEdits{DeviceNodes: []*specs.DeviceNode{node}}}
+		if err := edits.Apply(ociSpec); err != nil {
+			return fmt.Errorf("failed to inject device %q: %w", r, err)
+		}
+	}
+
+	return nil
+}
+
+// isPermissions checks if the string is a valid cgroup permission set.
+func isPermissions(perms string) bool {
+	if perms == "" || len(perms) > 3 {
+		return false
+	}
+	for _, c := range perms {
+		if c != 'r' && c != 'w' && c != 'm' {
+			return false
+		}
+		if strings.Count(perms, string(c)) > 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDeviceRequest(t *testing.T) {
+	testCases := []struct {
+		request string
+		result  *DeviceRequest
+	}{
+		{
+			request: "vendor.com/gpu=all",
+			result:  &DeviceRequest{Name: "vendor.com/gpu=all"},
+		},
+		{
+			request: "vendor.com/class=dev:rw",
+			result:  &DeviceRequest{Name: "vendor.com/class=dev", Permissions: "rw"},
+		},
+		{
+			request: "vendor.com/class=pci:0000:01",
+			result:  &DeviceRequest{Name: "vendor.com/class=pci:0000:01"},
+		},
+		{
+			request: "/dev/fuse",
+			result:  &DeviceRequest{HostPath: "/dev/fuse", ContainerPath: "/dev/fuse"},
+		},
+		{
+			request: "/dev/fuse:r",
+			result:  &DeviceRequest{HostPath: "/dev/fuse", ContainerPath: "/dev/fuse", Permissions: "r"},
+		},
+		{
+			request: "/dev/fuse:/dev/fuse0:mrw",
+			result:  &DeviceRequest{HostPath: "/dev/fuse", ContainerPath: "/dev/fuse0", Permissions: "mrw"},
+		},
+		{request: ""},
+		{request: "gpu0"},
+		{request: "vendor.com/class=:rw"},
+		{request: "/dev/fuse:fuse"},
+		{request: "/dev/fuse:/dev/fuse0:rx"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.request, func(t *testing.T) {
+			r, err := ParseDeviceRequest(tc.request)
+			if tc.result == nil {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.result, r)
+			require.Equal(t, tc.request, r.String())
+		})
+	}
+}
+
+func TestResolveDeviceRequests(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu1
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor1
+          type: c
+          major: 195
+          minor: 1
+  - name: gpu0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+`), 0o644))
+
+	cache, err := NewCache(WithSpecDirs(dir))
+	require.NoError(t, err)
+
+	requests, err := cache.ResolveDeviceRequests("vendor.com/gpu=gpu1", "vendor.com/gpu=all:r", "/dev/null:/dev/vendor-null")
+	require.NoError(t, err)
+	require.Equal(t, []*DeviceRequest{
+		{Name: "vendor.com/gpu=gpu1"},
+		{Name: "vendor.com/gpu=gpu0", Permissions: "r"},
+		{HostPath: "/dev/null", ContainerPath: "/dev/vendor-null"},
+	}, requests)
+
+	_, err = cache.ResolveDeviceRequests("vendor.com/gpu=gpu2")
+	require.Error(t, err)
+	_, err = cache.ResolveDeviceRequests("vendor.com/fpga=all")
+	require.Error(t, err)
+
+	spec := &oci.Spec{}
+	require.NoError(t, cache.InjectDeviceRequests(spec, requests[:2]))
+	require.Len(t, spec.Linux.Resources.Devices, 2)
+	require.Equal(t, "rwm", spec.Linux.Resources.Devices[0].Access)
+	require.Equal(t, "r", spec.Linux.Resources.Devices[1].Access)
+}
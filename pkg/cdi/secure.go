@@ -0,0 +1,135 @@
+package cdi
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WithSecureLoading returns an option to refuse loading Spec and drop-in
+// files, and Spec directories, which are group or world writable, not
+// owned by one of the given UIDs, root if none are given, or which are
+// symlinks pointing outside their Spec directory. By default files are
+// loaded without these checks.
+func WithSecureLoading(uids ...uint32) Option {
+	return func(c *Cache) error {
+		if len(uids) == 0 {
+			uids = []uint32{0}
+		}
+		c.secure = true
+		c.trustedUIDs = uids
+		return nil
+	}
+}
+
+// CheckSpecFileSecurity checks that a file in the given Spec directory,
+// or the directory itself, is not group or world writable, is owned by
+// one of the given UIDs and, if it is a symlink, points to a file in
+// the Spec directory.
+func CheckSpecFileSecurity(dir, path string, uids []uint32) error {
+	f, err := openSecureFile(dir, path, uids)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// openSecureFile opens a file in the given Spec directory, or the
+// directory itself, and checks it like CheckSpecFileSecurity. Symlinks
+// are resolved first, then the resolved file is opened without following
+// symlinks and the opened file is checked, so the checked file can't be
+// swapped before it is read.
+func openSecureFile(dir, path string, uids []uint32) (*os.File, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %q: %w", path, err)
+	}
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %q: %w", dir, err)
+	}
+	if target != root && !strings.HasPrefix(target, root+string(filepath.Separator)) {
+		return nil, fmt.Errorf("insecure %q: symlink to %q outside of %q", path, target, dir)
+	}
+
+	f, err := openNoFollow(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %q: %w", path, err)
+	}
+	if err := checkOpenFile(f, path, uids); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// checkOpenFile checks the permissions and owner of an opened file.
+func checkOpenFile(f *os.File, path string, uids []uint32) error {
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to check %q: %w", path, err)
+	}
+
+	if perm := info.Mode().Perm(); perm&0o022 != 0 {
+		return fmt.Errorf("insecure %q: group or world writable (mode %#o)", path, perm)
+	}
+
+	uid, err := fileOwner(info)
+	if err != nil {
+		return fmt.Errorf("failed to check %q: %w", path, err)
+	}
+	for _, trusted := range uids {
+		if uid == trusted {
+			return nil
+		}
+	}
+	return fmt.Errorf("insecure %q: owned by untrusted UID %d", path, uid)
+}
+
+// readSecureFile reads a regular file in the given Spec directory from
+// the same descriptor its security was checked on.
+func readSecureFile(dir, path string, uids []uint32) ([]byte, error) {
+	f, err := openSecureFile(dir, path, uids)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to check %q: %w", path, err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("insecure %q: not a regular file", path)
+	}
+	return ioutil.ReadAll(f)
+}
+
+// readSecureSpecs securely reads all CDI Specs from the given file in
+// the given Spec directory, like readSpecs.
+func readSecureSpecs(dir, path string, priority int, mode StrictMode, uids []uint32) ([]*Spec, []error, []error) {
+	data, err := readSecureFile(dir, path, uids)
+	if err != nil {
+		return nil, []error{err}, nil
+	}
+	return parseSpecs(path, data, priority, mode)
+}
+
+// readSecureDropIn securely reads a drop-in file, after checking its
+// drop-in directory and the Spec directory containing them.
+func readSecureDropIn(path string, uids []uint32) (*DropIn, error) {
+	dropInDir := filepath.Dir(path)
+	dir := filepath.Dir(dropInDir)
+	for _, p := range []string{dir, dropInDir} {
+		if err := CheckSpecFileSecurity(dir, p, uids); err != nil {
+			return nil, err
+		}
+	}
+	data, err := readSecureFile(dir, path, uids)
+	if err != nil {
+		return nil, err
+	}
+	return parseDropIn(path, data)
+}
@@ -0,0 +1,21 @@
+package cdi
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileOwner returns the UID owning a file.
+func fileOwner(info os.FileInfo) (uint32, error) {
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("can't determine owner of %q", info.Name())
+	}
+	return st.Uid, nil
+}
+
+// openNoFollow opens a file for reading, failing if it is a symlink.
+func openNoFollow(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+}
@@ -0,0 +1,153 @@
+//go:build linux
+// +build linux
+
+package cdi
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecureLoading(t *testing.T) {
+	uid := uint32(os.Getuid())
+
+	for _, tc := range []struct {
+		name     string
+		setup    func(t *testing.T, dir, outside string) string
+		uids     []uint32
+		rejected bool
+	}{
+		{
+			name: "secure file",
+			setup: func(t *testing.T, dir, _ string) string {
+				return writeSpecFile(t, dir, "vendor.yaml", 0o644)
+			},
+			uids: []uint32{uid},
+		},
+		{
+			name: "world writable file",
+			setup: func(t *testing.T, dir, _ string) string {
+				return writeSpecFile(t, dir, "vendor.yaml", 0o646)
+			},
+			uids:     []uint32{uid},
+			rejected: true,
+		},
+		{
+			name: "group writable file",
+			setup: func(t *testing.T, dir, _ string) string {
+				return writeSpecFile(t, dir, "vendor.yaml", 0o664)
+			},
+			uids:     []uint32{uid},
+			rejected: true,
+		},
+		{
+			name: "untrusted owner",
+			setup: func(t *testing.T, dir, _ string) string {
+				writeSpecFile(t, dir, "vendor.yaml", 0o644)
+				return dir
+			},
+			uids:     []uint32{uid + 1},
+			rejected: true,
+		},
+		{
+			name: "symlink within Spec directory",
+			setup: func(t *testing.T, dir, _ string) string {
+				require.NoError(t, os.Mkdir(filepath.Join(dir, "real"), 0o755))
+				writeSpecFile(t, filepath.Join(dir, "real"), "vendor.yaml", 0o644)
+				path := filepath.Join(dir, "vendor.yaml")
+				require.NoError(t, os.Symlink(filepath.Join(dir, "real", "vendor.yaml"), path))
+				return path
+			},
+			uids: []uint32{uid},
+		},
+		{
+			name: "symlink outside of Spec directory",
+			setup: func(t *testing.T, dir, outside string) string {
+				writeSpecFile(t, outside, "vendor.yaml", 0o644)
+				path := filepath.Join(dir, "vendor.yaml")
+				require.NoError(t, os.Symlink(filepath.Join(outside, "vendor.yaml"), path))
+				return path
+			},
+			uids:     []uint32{uid},
+			rejected: true,
+		},
+		{
+			name: "world writable Spec directory",
+			setup: func(t *testing.T, dir, _ string) string {
+				writeSpecFile(t, dir, "vendor.yaml", 0o644)
+				require.NoError(t, os.Chmod(dir, 0o777))
+				return dir
+			},
+			uids:     []uint32{uid},
+			rejected: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, outside := t.TempDir(), t.TempDir()
+			rejected := tc.setup(t, dir, outside)
+
+			cache, err := NewCache(WithSpecDirs(dir))
+			require.NoError(t, err)
+			require.Len(t, cache.ListDevices(), 1)
+
+			cache, err = NewCache(WithSpecDirs(dir), WithSecureLoading(tc.uids...))
+			require.NoError(t, err)
+			if !tc.rejected {
+				require.Empty(t, cache.GetErrors())
+				require.Len(t, cache.ListDevices(), 1)
+				return
+			}
+			require.Empty(t, cache.ListDevices())
+			require.Len(t, cache.GetErrors()[rejected], 1)
+			require.Contains(t, cache.GetErrors()[rejected][0].Error(), "insecure")
+		})
+	}
+}
+
+func TestSecureDropIns(t *testing.T) {
+	uid := uint32(os.Getuid())
+	dir := t.TempDir()
+	writeSpecFile(t, dir, "vendor.yaml", 0o644)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev1
+    containerEdits:
+      env: ["DEV1=1"]
+`), 0o644))
+	dropInDir := filepath.Join(dir, "vendor.com-device"+DropInDirSuffix)
+	require.NoError(t, os.Mkdir(dropInDir, 0o755))
+	dropIn := filepath.Join(dropInDir, "10-local.yaml")
+	require.NoError(t, ioutil.WriteFile(dropIn, []byte(`patches:
+  - op: add
+    device: dev0
+    containerEdits:
+      env: ["LOCAL=1"]
+`), 0o644))
+	require.NoError(t, os.Chmod(dropIn, 0o666))
+
+	cache, err := NewCache(WithSpecDirs(dir), WithSecureLoading(uid))
+	require.NoError(t, err)
+	require.Len(t, cache.GetErrors()[dropIn], 1)
+	dev := cache.GetDevice("vendor.com/device=dev0")
+	require.NotNil(t, dev)
+	require.Empty(t, dev.GetSpec().GetDropIns())
+}
+
+// writeSpecFile writes a Spec file with the given permissions.
+func writeSpecFile(t *testing.T, dir, name string, perm os.FileMode) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      env: ["DEV0=1"]
+`), 0o644))
+	require.NoError(t, os.Chmod(path, perm))
+	return path
+}
@@ -0,0 +1,210 @@
+package cdi
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+)
+
+// Selector selects CDI devices by kind, device name pattern and device
+// annotations. The selector syntax is
+//
+//	vendor.com/class[=pattern][{key op value,...}]
+//
+// pattern is a glob pattern for device names, as understood by path.Match,
+// for instance "*" or "gpu[0-3]". If omitted, every device of the kind
+// is selected. The optional predicates are matched against the device
+// annotations. op is one of =, !=, >=, <=, > or <. Values which are both
+// numbers, optionally with a Kubernetes quantity suffix such as Gi or M,
+// are compared numerically, other values are compared as strings. The
+// ordering operators need numeric values. A device without an annotation
+// never matches predicates for it.
+type Selector struct {
+	Vendor     string
+	Class      string
+	Pattern    string
+	Predicates []*Predicate
+}
+
+// Predicate is a single annotation predicate of a Selector.
+type Predicate struct {
+	Key   string
+	Op    string
+	Value string
+}
+
+// Predicate operators, two-character ones first for parsing.
+var predicateOps = []string{"!=", ">=", "<=", "=", ">", "<"}
+
+// quantitySuffixes are the multipliers of Kubernetes quantity suffixes.
+var quantitySuffixes = map[string]float64{
+	"":   1,
+	"m":  1e-3,
+	"k":  1e3,
+	"M":  1e6,
+	"G":  1e9,
+	"T":  1e12,
+	"P":  1e15,
+	"E":  1e18,
+	"Ki": 1 << 10,
+	"Mi": 1 << 20,
+	"Gi": 1 << 30,
+	"Ti": 1 << 40,
+	"Pi": 1 << 50,
+	"Ei": 1 << 60,
+}
+
+// IsSelector checks if the given device request is a selector rather
+// than a single qualified device name.
+func IsSelector(device string) bool {
+	return strings.ContainsAny(device, "*?[{") || !strings.Contains(device, "=")
+}
+
+// ParseSelector parses a device selector.
+func ParseSelector(selector string) (*Selector, error) {
+	s := &Selector{Pattern: "*"}
+
+	head := selector
+	if i := strings.Index(selector, "{"); i >= 0 {
+		if !strings.HasSuffix(selector, "}") {
+			return nil, fmt.Errorf("invalid selector %q, unterminated predicates", selector)
+		}
+		head = selector[:i]
+		for _, expr := range strings.Split(selector[i+1:len(selector)-1], ",") {
+			p, err := parsePredicate(expr)
+			if err != nil {
+				return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+			}
+			s.Predicates = append(s.Predicates, p)
+		}
+	}
+
+	kind := head
+	if i := strings.Index(head, "="); i >= 0 {
+		kind, s.Pattern = head[:i], head[i+1:]
+	}
+	s.Vendor, s.Class = parser.ParseQualifier(kind)
+	if s.Vendor == "" {
+		return nil, fmt.Errorf("invalid selector %q, invalid kind %q", selector, kind)
+	}
+	if err := ValidateVendorName(s.Vendor); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	if err := ValidateClassName(s.Class); err != nil {
+		return nil, fmt.Errorf("invalid selector %q: %w", selector, err)
+	}
+	if _, err := path.Match(s.Pattern, ""); err != nil || s.Pattern == "" {
+		return nil, fmt.Errorf("invalid selector %q, invalid device pattern %q", selector, s.Pattern)
+	}
+
+	return s, nil
+}
+
+// parsePredicate parses a single "key op value" predicate.
+func parsePredicate(expr string) (*Predicate, error) {
+	for i := range expr {
+		for _, op := range predicateOps {
+			if !strings.HasPrefix(expr[i:], op) {
+				continue
+			}
+			p := &Predicate{
+				Key:   strings.TrimSpace(expr[:i]),
+				Op:    op,
+				Value: strings.TrimSpace(expr[i+len(op):]),
+			}
+			if p.Key == "" {
+				return nil, fmt.Errorf("invalid predicate %q, empty key", expr)
+			}
+			if op != "=" && op != "!=" {
+				if _, ok := parseQuantity(p.Value); !ok {
+					return nil, fmt.Errorf("invalid predicate %q, %s needs a numeric value", expr, op)
+				}
+			}
+			return p, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid predicate %q, no operator", expr)
+}
+
+// Matches checks if the selector matches the device.
+func (s *Selector) Matches(d *Device) bool {
+	spec := d.GetSpec()
+	if spec.GetVendor() != s.Vendor || spec.GetClass() != s.Class {
+		return false
+	}
+	if ok, _ := path.Match(s.Pattern, d.Name); !ok {
+		return false
+	}
+	for _, p := range s.Predicates {
+		value, ok := d.Annotations[p.Key]
+		if !ok || !p.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches checks if the predicate holds for the given annotation value.
+func (p *Predicate) Matches(value string) bool {
+	a, aok := parseQuantity(value)
+	b, bok := parseQuantity(p.Value)
+	numeric := aok && bok
+
+	switch p.Op {
+	case "=":
+		return value == p.Value || (numeric && a == b)
+	case "!=":
+		return value != p.Value && !(numeric && a == b)
+	}
+	if !numeric {
+		return false
+	}
+	switch p.Op {
+	case ">=":
+		return a >= b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case "<":
+		return a < b
+	}
+	return false
+}
+
+// parseQuantity parses a number with an optional quantity suffix.
+func parseQuantity(value string) (float64, bool) {
+	i := len(value)
+	for i > 0 && (value[i-1] < '0' || value[i-1] > '9') && value[i-1] != '.' {
+		i--
+	}
+	mult, ok := quantitySuffixes[value[i:]]
+	if !ok || i == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(value[:i], 64)
+	if err != nil {
+		return 0, false
+	}
+	return n * mult, true
+}
+
+// ResolveSelector returns the sorted qualified names of the devices
+// matching the given selector.
+func (c *Cache) ResolveSelector(selector string) ([]string, error) {
+	s, err := ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []string
+	for _, name := range c.ListDevices() {
+		if d := c.GetDevice(name); d != nil && s.Matches(d) {
+			devices = append(devices, name)
+		}
+	}
+	return devices, nil
+}
@@ -0,0 +1,111 @@
+package cdi
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSelector(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor.yaml"), []byte(`cdiVersion: "0.6.0"
+kind: vendor.com/gpu
+devices:
+  - name: gpu0
+    annotations:
+      memory: 16Gi
+      arch: sm90
+    containerEdits:
+      env: ["GPU=0"]
+  - name: gpu1
+    annotations:
+      memory: 8Gi
+      arch: sm90
+    containerEdits:
+      env: ["GPU=1"]
+  - name: gpu10
+    annotations:
+      memory: "34359738368"
+      arch: sm80
+    containerEdits:
+      env: ["GPU=10"]
+  - name: mig0
+    containerEdits:
+      env: ["MIG=0"]
+`), 0o644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "other.yaml"), []byte(`cdiVersion: "0.6.0"
+kind: vendor.com/fpga
+devices:
+  - name: gpu0
+    containerEdits:
+      env: ["FPGA=0"]
+`), 0o644))
+
+	cache, err := NewCache(WithSpecDirs(dir))
+	require.NoError(t, err)
+	require.Empty(t, cache.GetErrors())
+
+	testCases := []struct {
+		selector string
+		devices  []string
+		invalid  bool
+	}{
+		{
+			selector: "vendor.com/gpu=*",
+			devices:  []string{"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1", "vendor.com/gpu=gpu10", "vendor.com/gpu=mig0"},
+		},
+		{
+			selector: "vendor.com/gpu=gpu[0-3]",
+			devices:  []string{"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1"},
+		},
+		{
+			selector: "vendor.com/gpu{memory>=16Gi,arch=sm90}",
+			devices:  []string{"vendor.com/gpu=gpu0"},
+		},
+		{
+			selector: "vendor.com/gpu{memory>8Gi}",
+			devices:  []string{"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu10"},
+		},
+		{
+			selector: "vendor.com/gpu=gpu*{arch!=sm90}",
+			devices:  []string{"vendor.com/gpu=gpu10"},
+		},
+		{
+			selector: "vendor.com/gpu{memory=32Gi}",
+			devices:  []string{"vendor.com/gpu=gpu10"},
+		},
+		{
+			selector: "vendor.com/fpga",
+			devices:  []string{"vendor.com/fpga=gpu0"},
+		},
+		{
+			selector: "vendor.com/gpu=npu*",
+		},
+		{selector: "vendor.com/gpu{arch>sm80}", invalid: true},
+		{selector: "vendor.com/gpu{arch}", invalid: true},
+		{selector: "vendor.com/gpu{arch=sm90", invalid: true},
+		{selector: "vendor.com/gpu=gpu[0-", invalid: true},
+		{selector: "gpu=*", invalid: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.selector, func(t *testing.T) {
+			devices, err := cache.ResolveSelector(tc.selector)
+			if tc.invalid {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.devices, devices)
+		})
+	}
+
+	requests, err := cache.ResolveDeviceRequests("vendor.com/gpu{arch=sm90}:rw", "vendor.com/gpu=gpu0")
+	require.NoError(t, err)
+	require.Equal(t, []*DeviceRequest{
+		{Name: "vendor.com/gpu=gpu0", Permissions: "rw"},
+		{Name: "vendor.com/gpu=gpu1", Permissions: "rw"},
+	}, requests)
+}
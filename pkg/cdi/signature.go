@@ -0,0 +1,270 @@
+package cdi
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// SignatureExt is the extension of detached Spec signature files.
+	// The signature file of a Spec file is the Spec file path with
+	// this extension appended.
+	SignatureExt = ".sig"
+	// publicKeyExt is the extension of trusted vendor public key files.
+	publicKeyExt = ".pem"
+)
+
+// SignatureMode controls how Spec signatures are checked while loading
+// Spec files into a Cache.
+type SignatureMode int
+
+const (
+	// SignatureModeDisabled does not check Spec signatures.
+	SignatureModeDisabled SignatureMode = iota
+	// SignatureModeWarn loads Specs with a missing or invalid signature
+	// but records the failed verification as a warning for the Spec file.
+	SignatureModeWarn
+	// SignatureModeError refuses to load Specs with a missing or invalid
+	// signature.
+	SignatureModeError
+)
+
+var (
+	// ErrSignatureMissing is returned if a Spec has no signature.
+	ErrSignatureMissing = errors.New("missing Spec signature")
+	// ErrSignatureInvalid is returned if no Spec signature verifies
+	// with any of the trusted keys of the Spec vendor.
+	ErrSignatureInvalid = errors.New("invalid Spec signature")
+	// ErrUntrustedVendor is returned if there are no trusted keys for
+	// the Spec vendor.
+	ErrUntrustedVendor = errors.New("no trusted keys for Spec vendor")
+)
+
+// WithSignatureVerification returns an option to verify Spec signatures
+// against the given trust store. By default signatures are not checked.
+// With signature verification enabled Spec drop-ins are refused.
+func WithSignatureVerification(store *TrustStore, mode SignatureMode) Option {
+	return func(c *Cache) error {
+		switch mode {
+		case SignatureModeDisabled:
+		case SignatureModeWarn, SignatureModeError:
+			if store == nil {
+				return fmt.Errorf("signature verification requires a trust store")
+			}
+		default:
+			return fmt.Errorf("invalid signature mode %d", mode)
+		}
+		c.trust = store
+		c.signature = mode
+		return nil
+	}
+}
+
+// TrustStore stores the trusted public keys of Spec vendors.
+type TrustStore struct {
+	sync.RWMutex
+	keys map[string][]ed25519.PublicKey
+}
+
+// NewTrustStore creates a new, empty TrustStore.
+func NewTrustStore() *TrustStore {
+	return &TrustStore{
+		keys: map[string][]ed25519.PublicKey{},
+	}
+}
+
+// LoadTrustStore creates a TrustStore from the public keys in the given
+// directory. Each file named "<vendor>.pem" holds one or more PEM-encoded
+// ed25519 public keys trusted for the vendor.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load trust store %q: %w", dir, err)
+	}
+
+	t := NewTrustStore()
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != publicKeyExt {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load trust store %q: %w", dir, err)
+		}
+		keys, err := ParsePublicKeys(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public keys %q: %w", path, err)
+		}
+		vendor := strings.TrimSuffix(e.Name(), publicKeyExt)
+		if err := ValidateVendorName(vendor); err != nil {
+			return nil, fmt.Errorf("failed to load public keys %q: %w", path, err)
+		}
+		for _, key := range keys {
+			t.AddKey(vendor, key)
+		}
+	}
+
+	return t, nil
+}
+
+// AddKey adds a trusted public key for the given vendor.
+func (t *TrustStore) AddKey(vendor string, key ed25519.PublicKey) {
+	t.Lock()
+	defer t.Unlock()
+	t.keys[vendor] = append(t.keys[vendor], key)
+}
+
+// GetKeys returns the trusted public keys for the given vendor.
+func (t *TrustStore) GetKeys(vendor string) []ed25519.PublicKey {
+	t.RLock()
+	defer t.RUnlock()
+	return t.keys[vendor]
+}
+
+// Verify verifies the detached signature of the given Spec against the
+// trusted keys of the Spec vendor. The signature is read from the Spec
+// file path with SignatureExt appended.
+func (t *TrustStore) Verify(spec *Spec) error {
+	keys := t.GetKeys(spec.GetVendor())
+	if len(keys) == 0 {
+		return fmt.Errorf("%w %q", ErrUntrustedVendor, spec.GetVendor())
+	}
+
+	sigs, err := ReadSignatures(spec.GetPath())
+	if err != nil {
+		return err
+	}
+
+	return VerifySpec(spec.Spec, sigs, keys)
+}
+
+// SignSpec signs the canonical encoding of the given raw CDI Spec.
+func SignSpec(raw *cdi.Spec, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := CanonicalSpec(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign CDI Spec: %w", err)
+	}
+	return ed25519.Sign(key, data), nil
+}
+
+// VerifySpec verifies that one of the given signatures is a signature
+// of the canonical encoding of the raw CDI Spec by one of the given keys.
+func VerifySpec(raw *cdi.Spec, sigs [][]byte, keys []ed25519.PublicKey) error {
+	data, err := CanonicalSpec(raw)
+	if err != nil {
+		return fmt.Errorf("failed to verify CDI Spec: %w", err)
+	}
+	for _, sig := range sigs {
+		for _, key := range keys {
+			if ed25519.Verify(key, data, sig) {
+				return nil
+			}
+		}
+	}
+	return ErrSignatureInvalid
+}
+
+// SignSpecFile signs every Spec in the given Spec file and writes the
+// signatures, one per line, into the detached signature file.
+func SignSpecFile(path string, key ed25519.PrivateKey) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to sign CDI Spec %q: %w", path, err)
+	}
+
+	specs, errs := ParseSpecs(data)
+	if len(errs) > 0 {
+		return fmt.Errorf("failed to sign CDI Spec %q: %w", path, errs[0])
+	}
+	if len(specs) == 0 {
+		return fmt.Errorf("failed to sign CDI Spec %q: no Spec data", path)
+	}
+
+	buf := &bytes.Buffer{}
+	for _, raw := range specs {
+		sig, err := SignSpec(raw, key)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(base64.StdEncoding.EncodeToString(sig))
+		buf.WriteByte('\n')
+	}
+
+	if err := ioutil.WriteFile(path+SignatureExt, buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("failed to write CDI Spec signature: %w", err)
+	}
+	return nil
+}
+
+// ReadSignatures reads the detached signatures of the given Spec file.
+// The signature file holds one base64-encoded signature per line.
+func ReadSignatures(path string) ([][]byte, error) {
+	data, err := ioutil.ReadFile(path + SignatureExt)
+	switch {
+	case os.IsNotExist(err):
+		return nil, fmt.Errorf("%w %q", ErrSignatureMissing, path+SignatureExt)
+	case err != nil:
+		return nil, fmt.Errorf("failed to read CDI Spec signature: %w", err)
+	}
+
+	var sigs [][]byte
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode CDI Spec signature %q: %w", path+SignatureExt, err)
+		}
+		sigs = append(sigs, sig)
+	}
+	if len(sigs) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrSignatureMissing, path+SignatureExt)
+	}
+
+	return sigs, nil
+}
+
+// ParsePublicKeys parses PEM-encoded PKIX ed25519 public keys.
+func ParsePublicKeys(data []byte) ([]ed25519.PublicKey, error) {
+	var keys []ed25519.PublicKey
+
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "PUBLIC KEY" {
+			continue
+		}
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		key, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("unsupported public key type %T", pub)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no public keys found")
+	}
+
+	return keys, nil
+}
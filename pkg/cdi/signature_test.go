@@ -0,0 +1,203 @@
+package cdi
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureVerification(t *testing.T) {
+	const (
+		specData = `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+`
+		tamperedData = `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+      hooks:
+        - hookName: createContainer
+          path: /tmp/evil
+`
+		tamperedConditionalData = `cdiVersion: "0.8.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+      conditional:
+        - arch: [ amd64, arm64 ]
+          containerEdits:
+            hooks:
+              - hookName: createContainer
+                path: /tmp/evil
+`
+	)
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	keyDir := t.TempDir()
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+	pemData := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(keyDir, "vendor.com.pem"), pemData, 0o644))
+
+	store, err := LoadTrustStore(keyDir)
+	require.NoError(t, err)
+	require.Len(t, store.GetKeys("vendor.com"), 1)
+
+	type testCase struct {
+		name    string
+		sign    ed25519.PrivateKey
+		data    string
+		invalid error
+	}
+	for _, tc := range []*testCase{
+		{
+			name: "valid signature",
+			sign: key,
+			data: specData,
+		},
+		{
+			name:    "missing signature",
+			data:    specData,
+			invalid: ErrSignatureMissing,
+		},
+		{
+			name:    "untrusted signer",
+			sign:    otherKey,
+			data:    specData,
+			invalid: ErrSignatureInvalid,
+		},
+		{
+			name:    "tampered Spec",
+			sign:    key,
+			data:    tamperedData,
+			invalid: ErrSignatureInvalid,
+		},
+		{
+			name:    "tampered conditional edits",
+			sign:    key,
+			data:    tamperedConditionalData,
+			invalid: ErrSignatureInvalid,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "vendor.yaml")
+
+			require.NoError(t, ioutil.WriteFile(path, []byte(specData), 0o644))
+			if tc.sign != nil {
+				require.NoError(t, SignSpecFile(path, tc.sign))
+			}
+			require.NoError(t, ioutil.WriteFile(path, []byte(tc.data), 0o644))
+
+			cache, err := NewCache(
+				WithSpecDirs(dir),
+				WithSignatureVerification(store, SignatureModeError),
+			)
+			require.NoError(t, err)
+
+			if tc.invalid == nil {
+				require.Equal(t, []string{"vendor.com/device=dev0"}, cache.ListDevices())
+				require.Empty(t, cache.GetErrors())
+			} else {
+				require.Empty(t, cache.ListDevices())
+				errs := cache.GetErrors()[path]
+				require.Len(t, errs, 1)
+				require.True(t, errors.Is(errs[0], tc.invalid))
+			}
+
+			require.NoError(t, cache.Configure(
+				WithSignatureVerification(store, SignatureModeWarn),
+			))
+			require.Equal(t, []string{"vendor.com/device=dev0"}, cache.ListDevices())
+			require.Empty(t, cache.GetErrors())
+			if tc.invalid == nil {
+				require.Empty(t, cache.GetWarnings())
+			} else {
+				warnings := cache.GetWarnings()[path]
+				require.Len(t, warnings, 1)
+				require.True(t, errors.Is(warnings[0], tc.invalid))
+			}
+		})
+	}
+}
+
+func TestSignatureVerificationDropIns(t *testing.T) {
+	const (
+		specData = `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+`
+		dropInData = `patches:
+  - op: add
+    device: dev0
+    containerEdits:
+      hooks:
+        - hookName: createContainer
+          path: /tmp/evil
+`
+	)
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	store := NewTrustStore()
+	store.AddKey("vendor.com", pub)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "vendor.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(specData), 0o644))
+	require.NoError(t, SignSpecFile(path, key))
+
+	dropInDir := filepath.Join(dir, "vendor.com-device"+DropInDirSuffix)
+	dropInPath := filepath.Join(dropInDir, "10-hook.yaml")
+	require.NoError(t, os.MkdirAll(dropInDir, 0o755))
+	require.NoError(t, ioutil.WriteFile(dropInPath, []byte(dropInData), 0o644))
+
+	for _, mode := range []SignatureMode{SignatureModeWarn, SignatureModeError} {
+		cache, err := NewCache(
+			WithSpecDirs(dir),
+			WithSignatureVerification(store, mode),
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"vendor.com/device=dev0"}, cache.ListDevices())
+		require.Len(t, cache.GetErrors()[dropInPath], 1)
+		require.Empty(t, cache.GetErrors()[path])
+
+		edits, _, err := cache.GetInjectedEdits("vendor.com/device=dev0")
+		require.NoError(t, err)
+		require.Empty(t, edits.Hooks)
+		require.Empty(t, cache.GetDevice("vendor.com/device=dev0").GetSpec().GetDropIns())
+	}
+
+	cache, err := NewCache(WithSpecDirs(dir))
+	require.NoError(t, err)
+	edits, _, err := cache.GetInjectedEdits("vendor.com/device=dev0")
+	require.NoError(t, err)
+	require.Len(t, edits.Hooks, 1)
+}
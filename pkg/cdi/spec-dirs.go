@@ -0,0 +1,96 @@
+package cdi
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+const (
+	// DefaultStaticDir is the default directory for static CDI Specs.
+	DefaultStaticDir = "/etc/cdi"
+	// DefaultDynamicDir is the default directory for generated CDI Specs
+	DefaultDynamicDir = "/var/run/cdi"
+)
+
+var (
+	// DefaultSpecDirs is the default Spec directory configuration.
+	// The preferred way of overriding the default directories is
+	// to use a WithSpecDirs option.
+	DefaultSpecDirs = []string{DefaultStaticDir, DefaultDynamicDir}
+	// ErrStopScan can be returned from a scanSpecFunc to stop the scan.
+	ErrStopScan = errors.New("stop Spec scan")
+)
+
+// WithSpecDirs returns an option to override the CDI Spec directories.
+// Directories are listed in increasing order of priority.
+func WithSpecDirs(dirs ...string) Option {
+	return func(c *Cache) error {
+		specDirs := make([]string, len(dirs))
+		for i, dir := range dirs {
+			specDirs[i] = filepath.Clean(dir)
+		}
+		c.specDirs = specDirs
+		c.dirPriorities = nil
+		return nil
+	}
+}
+
+// scanSpecFunc is a function for processing CDI Spec files.
+type scanSpecFunc func(path string, priority int) error
+
+// scanSpecDirs scans the given directories looking for CDI Spec files,
+// which are all files with a '.json' or '.yaml' suffix. For every Spec
+// file discovered, scanSpecDirs calls the scan function passing it the
+// path to the file and the priority of its directory, taken from the
+// given priorities or, if they are nil, the index of the directory in
+// the slice of directories given.
+//
+// Scanning stops once all files have been processed or when the scan
+// function returns an error. The special error ErrStopScan can be used
+// to terminate the scan gracefully. scanSpecDirs silently skips any
+// subdirectories and missing directories.
+func scanSpecDirs(dirs []string, priorities []int, scanFn scanSpecFunc) error {
+	for i, dir := range dirs {
+		priority := specDirPriority(priorities, i)
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			// for initial stat failure Walk calls us with nil info
+			if info == nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			// first call from Walk is for dir itself, others we skip
+			if info.IsDir() {
+				if path == dir {
+					return nil
+				}
+				return filepath.SkipDir
+			}
+
+			// ignore obviously non-Spec files
+			if ext := filepath.Ext(path); ext != ".json" && ext != ".yaml" {
+				return nil
+			}
+
+			return scanFn(path, priority)
+		})
+
+		if err != nil && err != ErrStopScan {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// specDirPriority returns the priority of the Spec directory with the
+// given index, the index itself unless explicit priorities are given.
+func specDirPriority(priorities []int, idx int) int {
+	if priorities == nil {
+		return idx
+	}
+	return priorities[idx]
+}
@@ -1,18 +1,22 @@
 package cdi
 
 import (
-	// i mean this imports should be changed to YOURS
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sync"
 
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
 	oci "github.com/opencontainers/runtime-spec/specs-go"
 	"sigs.k8s.io/yaml"
 
-	cdi "github.com/container-orchestrated-devices/container-device-interface/specs-go"
+	"container-device-interface-aaron/internal/stream"
+	"container-device-interface-aaron/internal/validation"
+	cdi "container-device-interface-aaron/specs-go"
 )
 
 const (
@@ -40,6 +44,7 @@ type Spec struct {
 	path     string
 	priority int
 	devices  map[string]*Device // pending to be written.
+	dropIns  []string
 }
 
 // ReadSpec reads the given CDI Spec file. The resulting Spec is
@@ -70,12 +75,69 @@ func ReadSpec(path string, priority int) (*Spec, error) {
 	return spec, nil
 }
 
+// ReadSpecs reads all CDI Specs from the given multi-document YAML
+// or JSON Lines file. The resulting Specs are assigned the given
+// priority. Each document is parsed and validated independently.
+// ReadSpecs returns the Specs of all valid documents together with
+// a *DocumentError for each document that failed to parse or validate.
+func ReadSpecs(path string, priority int) ([]*Spec, []error) {
+	specs, errs, _ := readSpecs(path, priority, StrictModeDisabled)
+	return specs, errs
+}
+
+// readSpecs reads all CDI Specs from the given file, checking each
+// document for unknown and duplicate keys according to the given strict
+// mode. In StrictModeWarn such keys are returned as warnings, while in
+// StrictModeError the offending documents are rejected with an error.
+func readSpecs(path string, priority int, mode StrictMode) ([]*Spec, []error, []error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to read CDI Spec: %q: %w", path, err)}, nil
+	}
+	return parseSpecs(path, data, priority, mode)
+}
+
+// parseSpecs parses all CDI Specs from the given data read from path,
+// like readSpecs.
+func parseSpecs(path string, data []byte, priority int, mode StrictMode) ([]*Spec, []error, []error) {
+	var (
+		specs    []*Spec
+		errs     []error
+		warnings []error
+	)
+	for _, doc := range stream.Split(data) {
+		raw, err := parseSpec(doc.Data)
+		if err == nil && raw == nil {
+			err = fmt.Errorf("no Spec data")
+		}
+		if err == nil && mode != StrictModeDisabled {
+			if keyErr := checkSpecKeys(doc.Data); keyErr != nil {
+				if mode == StrictModeError {
+					err = keyErr
+				} else {
+					warnings = append(warnings, fmt.Errorf("CDI Spec %q: %w", path, doc.Wrap(keyErr)))
+				}
+			}
+		}
+		if err == nil {
+			var spec *Spec
+			if spec, err = newSpec(raw, path, priority); err == nil {
+				specs = append(specs, spec)
+				continue
+			}
+		}
+		errs = append(errs, fmt.Errorf("failed to read CDI Spec: %q: %w", path, doc.Wrap(err)))
+	}
+
+	return specs, errs, warnings
+}
+
 // newSpec creates a new Spec from the ive CDI Spec data. The
 // Spec is marked as loaded from the given path with the given
 // priority. If Spec data validation fails newSpec return a nil
 // Spec and an error.
 func newSpec(raw *cdi.Spec, path string, priority int) (*Spec, error) {
-	err := ValidateSpec(raw)
+	err := validateSpec(raw)
 	if err != nil {
 		return nil, err
 	}
@@ -89,7 +151,7 @@ func newSpec(raw *cdi.Spec, path string, priority int) (*Spec, error) {
 		spec.path += defaultSpecExt
 	}
 
-	spec.vendor, spec.class = ParserQualifier(spec.Kind)
+	spec.vendor, spec.class = parser.ParseQualifier(spec.Kind)
 
 	if spec.devices, err = spec.validate(); err != nil {
 		return nil, fmt.Errorf("invalid CDI Spec: %w", err)
@@ -140,7 +202,7 @@ func (s *Spec) write() error {
 		return fmt.Errorf("failed to write Spec file: %w", err)
 	}
 
-	err = renameIn(dir, filepath.Base(tmp.Name()), filepath.Base(s.path), overwrite)
+	err = renameIn(dir, filepath.Base(tmp.Name()), filepath.Base(s.path), true)
 
 	if err != nil {
 		os.Remove(tmp.Name())
@@ -205,7 +267,7 @@ func (s *Spec) validate() (map[string]*Device, error) {
 	if err := ValidateClassName(s.class); err != nil {
 		return nil, err
 	}
-	if err := ValidateSpecAnnotations(s.Kind, s.Annotations); err != nil {
+	if err := validation.ValidateSpecAnnotations(s.Kind, s.Annotations); err != nil {
 		return nil, err
 	}
 	if err := s.edits().Validate(); err != nil {
@@ -214,7 +276,7 @@ func (s *Spec) validate() (map[string]*Device, error) {
 
 	devices := make(map[string]*Device)
 	for _, d := range s.Devices {
-		dev, err := newDevice(d, s) // this function is important to me
+		dev, err := newDevice(s, d)
 		if err != nil {
 			return nil, fmt.Errorf("failed add device %q: %w", d.Name, err)
 		}
@@ -223,6 +285,12 @@ func (s *Spec) validate() (map[string]*Device, error) {
 		}
 		devices[d.Name] = dev
 	}
+	if err := resolveDevices(devices); err != nil {
+		return nil, err
+	}
+	if err := checkRelations(devices); err != nil {
+		return nil, err
+	}
 	return devices, nil
 }
 
@@ -234,8 +302,70 @@ func validateVersion(version string) error {
 	return nil
 }
 
-// ParseSpec parses CDI Spec data into a raw CDI Spec
+// DocumentError is an error for a single document of a multi-document
+// YAML or JSON Lines stream of CDI Specs.
+type DocumentError = stream.Error
+
+// ParseSpec parses CDI Spec data into a raw CDI Spec. JSON data is
+// decoded directly, other data is decoded as YAML. Data with more than
+// one document is rejected, use ParseSpecs to parse such data.
 func ParseSpec(data []byte) (*cdi.Spec, error) {
+	if docs := stream.Split(data); len(docs) > 1 {
+		return nil, fmt.Errorf("failed to unmarshal CDI Spec: found %d documents, expected 1", len(docs))
+	}
+	return parseSpec(data)
+}
+
+// ParseSpecs parses each document of a multi-document YAML or JSON
+// Lines stream into a raw CDI Spec. It returns the Specs of all parsed
+// documents together with a *DocumentError for each document that
+// could not be parsed.
+func ParseSpecs(data []byte) ([]*cdi.Spec, []error) {
+	var (
+		specs []*cdi.Spec
+		errs  []error
+	)
+	for _, doc := range stream.Split(data) {
+		raw, err := parseSpec(doc.Data)
+		switch {
+		case err != nil:
+			errs = append(errs, doc.Wrap(err))
+		case raw == nil:
+			errs = append(errs, doc.Wrap(fmt.Errorf("no Spec data")))
+		default:
+			specs = append(specs, raw)
+		}
+	}
+	return specs, errs
+}
+
+// parseSpec parses a single document of CDI Spec data, decoding JSON
+// data directly and falling back to YAML decoding for anything else,
+// including YAML flow style documents which look like JSON.
+func parseSpec(data []byte) (*cdi.Spec, error) {
+	if stream.IsJSON(data) {
+		if raw, err := parseSpecJSON(data); err == nil {
+			return raw, nil
+		}
+	}
+	return parseSpecYAML(data)
+}
+
+// parseSpecJSON parses a single JSON document of CDI Spec data.
+func parseSpecJSON(data []byte) (*cdi.Spec, error) {
+	var raw *cdi.Spec
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if err := dec.Decode(&raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal CDI Spec: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("failed to unmarshal CDI Spec: found multiple documents, expected 1")
+	}
+	return raw, nil
+}
+
+// parseSpecYAML parses a single YAML document of CDI Spec data.
+func parseSpecYAML(data []byte) (*cdi.Spec, error) {
 	var raw *cdi.Spec
 	err := yaml.Unmarshal(data, &raw)
 	if err != nil {
@@ -283,3 +413,16 @@ func validateSpec(raw *cdi.Spec) error {
 func GenerateSpecName(vendor, class string) string {
 	return vendor + "-" + class
 }
+
+// renameIn renames src to dst in dir. If overwrite is false, an existing
+// dst is not replaced.
+func renameIn(dir, src, dst string, overwrite bool) error {
+	src = filepath.Join(dir, src)
+	dst = filepath.Join(dir, dst)
+
+	if _, err := os.Stat(dst); err == nil && !overwrite {
+		return os.ErrExist
+	}
+
+	return os.Rename(src, dst)
+}
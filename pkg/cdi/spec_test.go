@@ -0,0 +1,168 @@
+package cdi
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/yaml"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+func TestParseSpecJSON(t *testing.T) {
+	raw := generateSpec(16)
+
+	data, err := json.Marshal(raw)
+	require.NoError(t, err)
+
+	fast, err := ParseSpec(data)
+	require.NoError(t, err)
+	slow, err := parseSpecYAML(data)
+	require.NoError(t, err)
+	require.Equal(t, slow, fast)
+	require.Equal(t, raw, fast)
+
+	_, err = ParseSpec(append(append(data, '\n'), data...))
+	require.Error(t, err)
+
+	_, err = ParseSpec([]byte(`{"cdiVersion": "0.3.0", "kind": `))
+	require.Error(t, err)
+
+	flow, err := ParseSpec([]byte(`{cdiVersion: "0.3.0", kind: vendor.com/device, devices: [{name: dev0}]}`))
+	require.NoError(t, err)
+	require.Equal(t, "vendor.com/device", flow.Kind)
+	require.Equal(t, "dev0", flow.Devices[0].Name)
+}
+
+func TestNewSpecAnnotations(t *testing.T) {
+	type testCase struct {
+		name       string
+		spec       map[string]string
+		device     map[string]string
+		shouldFail bool
+	}
+	for _, tc := range []*testCase{
+		{
+			name:   "valid annotations",
+			spec:   map[string]string{"vendor.com/key": "value"},
+			device: map[string]string{"key": "value"},
+		},
+		{
+			name:       "invalid Spec annotation key",
+			spec:       map[string]string{"bad key!!": "value"},
+			shouldFail: true,
+		},
+		{
+			name:       "invalid Spec annotation prefix",
+			spec:       map[string]string{"__/__": "value"},
+			shouldFail: true,
+		},
+		{
+			name:       "invalid device annotation key",
+			device:     map[string]string{"bad key!!": "value"},
+			shouldFail: true,
+		},
+		{
+			name:       "invalid device annotation prefix",
+			device:     map[string]string{"__/__": "value"},
+			shouldFail: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := generateSpec(1)
+			raw.Version = "0.6.0"
+			raw.Annotations = tc.spec
+			raw.Devices[0].Annotations = tc.device
+
+			_, err := newSpec(raw, "/etc/cdi/vendor.yaml", 0)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func BenchmarkParseSpec(b *testing.B) {
+	for _, count := range []int{16, 256} {
+		raw := generateSpec(count)
+
+		jsonData, err := json.Marshal(raw)
+		require.NoError(b, err)
+		yamlData, err := yaml.Marshal(raw)
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("json/%d-devices", count), func(b *testing.B) {
+			b.SetBytes(int64(len(jsonData)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parseSpecJSON(jsonData); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("json-via-yaml/%d-devices", count), func(b *testing.B) {
+			b.SetBytes(int64(len(jsonData)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parseSpecYAML(jsonData); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("yaml/%d-devices", count), func(b *testing.B) {
+			b.SetBytes(int64(len(yamlData)))
+			for i := 0; i < b.N; i++ {
+				if _, err := parseSpecYAML(yamlData); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// generateSpec generates a raw CDI Spec with the given number of devices.
+func generateSpec(count int) *cdi.Spec {
+	raw := &cdi.Spec{
+		Version: "0.5.0",
+		Kind:    "vendor.com/device",
+		ContainerEdits: cdi.ContainerEdits{
+			Env: []string{"VENDOR_DRIVER=1"},
+			Mounts: []*cdi.Mount{
+				{
+					HostPath:      "/usr/lib/libvendor.so",
+					ContainerPath: "/usr/lib/libvendor.so",
+					Options:       []string{"ro", "nosuid", "nodev", "bind"},
+				},
+			},
+		},
+	}
+
+	for i := 0; i < count; i++ {
+		raw.Devices = append(raw.Devices, cdi.Device{
+			Name: fmt.Sprintf("dev%d", i),
+			ContainerEdits: cdi.ContainerEdits{
+				Env: []string{fmt.Sprintf("VENDOR_DEVICE_%d=1", i)},
+				DeviceNodes: []*cdi.DeviceNode{
+					{
+						Path:     fmt.Sprintf("/dev/vendor%d", i),
+						HostPath: fmt.Sprintf("/dev/vendor%d", i),
+						Type:     "c",
+						Major:    195,
+						Minor:    int64(i),
+					},
+				},
+				Hooks: []*cdi.Hook{
+					{
+						HookName: "createContainer",
+						Path:     "/usr/bin/vendor-hook",
+						Args:     []string{"vendor-hook", "--device", fmt.Sprintf("%d", i)},
+					},
+				},
+			},
+		})
+	}
+
+	return raw
+}
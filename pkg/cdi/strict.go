@@ -0,0 +1,194 @@
+package cdi
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+// StrictMode controls how unknown and duplicate keys in CDI Spec data
+// are treated while loading Spec files into a Cache.
+type StrictMode int
+
+const (
+	// StrictModeDisabled silently ignores unknown and duplicate keys.
+	StrictModeDisabled StrictMode = iota
+	// StrictModeWarn loads Specs with unknown or duplicate keys but
+	// records the offending keys as warnings for the Spec file.
+	StrictModeWarn
+	// StrictModeError refuses to load Specs with unknown or duplicate keys.
+	StrictModeError
+)
+
+// WithStrictMode returns an option to control the strictness of Spec
+// decoding. By default strict decoding is disabled.
+func WithStrictMode(mode StrictMode) Option {
+	return func(c *Cache) error {
+		switch mode {
+		case StrictModeDisabled, StrictModeWarn, StrictModeError:
+		default:
+			return fmt.Errorf("invalid strict mode %d", mode)
+		}
+		c.strict = mode
+		return nil
+	}
+}
+
+// KeyPath is the location of a key in CDI Spec data.
+type KeyPath struct {
+	// Path is the path of the key, for instance "devices[0].containerEdit".
+	Path string
+	// Line is the line number of the key in the Spec data.
+	Line int
+}
+
+// String returns the path and line of the key.
+func (k KeyPath) String() string {
+	return fmt.Sprintf("%q (line %d)", k.Path, k.Line)
+}
+
+// StrictError lists the unknown and duplicate keys found by strict
+// decoding of CDI Spec data.
+type StrictError struct {
+	Unknown   []KeyPath
+	Duplicate []KeyPath
+}
+
+// Error returns all offending keys as a single error string.
+func (e *StrictError) Error() string {
+	var issues []string
+	for _, k := range e.Unknown {
+		issues = append(issues, "unknown key "+k.String())
+	}
+	for _, k := range e.Duplicate {
+		issues = append(issues, "duplicate key "+k.String())
+	}
+	return "strict decoding failed: " + strings.Join(issues, ", ")
+}
+
+// isEmpty returns true if no offending keys were found.
+func (e *StrictError) isEmpty() bool {
+	return len(e.Unknown)+len(e.Duplicate) == 0
+}
+
+// ParseSpecStrict parses CDI Spec data into a raw CDI Spec like
+// ParseSpec, but fails with a *StrictError if the data contains any
+// unknown or duplicate keys.
+func ParseSpecStrict(data []byte) (*cdi.Spec, error) {
+	raw, err := ParseSpec(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSpecKeys(data); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// checkSpecKeys checks a single document of CDI Spec data for unknown
+// and duplicate keys. It returns a *StrictError if any are found.
+func checkSpecKeys(data []byte) error {
+	var node yaml.Node
+
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return fmt.Errorf("failed to unmarshal CDI Spec: %w", err)
+	}
+
+	e := &StrictError{}
+	e.check(&node, reflect.TypeOf(cdi.Spec{}), "")
+	if e.isEmpty() {
+		return nil
+	}
+	return e
+}
+
+// check collects the unknown and duplicate keys of the given node,
+// expected to decode into a value of the given type.
+func (e *StrictError) check(node *yaml.Node, t reflect.Type, path string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, n := range node.Content {
+			e.check(n, t, path)
+		}
+	case yaml.AliasNode:
+		e.check(node.Alias, t, path)
+	case yaml.SequenceNode:
+		if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+			return
+		}
+		for i, n := range node.Content {
+			e.check(n, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case yaml.MappingNode:
+		var fields map[string]reflect.Type
+		switch t.Kind() {
+		case reflect.Struct:
+			fields = jsonFields(t)
+		case reflect.Map:
+		default:
+			return
+		}
+
+		seen := map[string]struct{}{}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				e.check(value, t, path)
+				continue
+			}
+			keyPath := joinKeyPath(path, key.Value)
+			if _, ok := seen[key.Value]; ok {
+				e.Duplicate = append(e.Duplicate, KeyPath{Path: keyPath, Line: key.Line})
+				continue
+			}
+			seen[key.Value] = struct{}{}
+
+			if t.Kind() == reflect.Map {
+				e.check(value, t.Elem(), keyPath)
+				continue
+			}
+			ft, ok := fields[key.Value]
+			if !ok {
+				e.Unknown = append(e.Unknown, KeyPath{Path: keyPath, Line: key.Line})
+				continue
+			}
+			e.check(value, ft, keyPath)
+		}
+	}
+}
+
+// jsonFields returns the JSON field names and types of a struct type.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// joinKeyPath appends a key to a key path.
+func joinKeyPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
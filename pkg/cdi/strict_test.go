@@ -0,0 +1,106 @@
+package cdi
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpecStrict(t *testing.T) {
+	testCases := []struct {
+		name      string
+		data      string
+		unknown   []KeyPath
+		duplicate []KeyPath
+	}{
+		{
+			name: "valid YAML",
+			data: `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      mounts:
+        - hostPath: /usr/lib/libfoo.so
+          containerPath: /usr/lib/libfoo.so
+`,
+		},
+		{
+			name: "unknown keys in YAML",
+			data: `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdit:
+      env:
+        - FOO=bar
+  - name: dev1
+    containerEdits:
+      mounts:
+        - hostpath: /usr/lib/libfoo.so
+          containerPath: /usr/lib/libfoo.so
+`,
+			unknown: []KeyPath{
+				{Path: "devices[0].containerEdit", Line: 5},
+				{Path: "devices[1].containerEdits.mounts[0].hostpath", Line: 11},
+			},
+		},
+		{
+			name: "duplicate keys in YAML",
+			data: `cdiVersion: "0.3.0"
+kind: vendor.com/device
+kind: vendor.com/other
+annotations:
+  foo: bar
+  foo: baz
+devices:
+  - name: dev0
+    containerEdits:
+      env:
+        - FOO=bar
+`,
+			duplicate: []KeyPath{
+				{Path: "kind", Line: 3},
+				{Path: "annotations.foo", Line: 6},
+			},
+		},
+		{
+			name: "unknown and duplicate keys in JSON",
+			data: `{
+  "cdiVersion": "0.3.0",
+  "kind": "vendor.com/device",
+  "devices": [
+    {
+      "name": "dev0",
+      "name": "dev1",
+      "containerEdits": {"deviceNodes": [{"path": "/dev/dev0", "hostpath": "/dev/dev0"}]}
+    }
+  ]
+}`,
+			unknown: []KeyPath{
+				{Path: "devices[0].containerEdits.deviceNodes[0].hostpath", Line: 8},
+			},
+			duplicate: []KeyPath{
+				{Path: "devices[0].name", Line: 7},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw, err := ParseSpecStrict([]byte(tc.data))
+			if tc.unknown == nil && tc.duplicate == nil {
+				require.NoError(t, err)
+				require.NotNil(t, raw)
+				return
+			}
+
+			require.Error(t, err)
+			require.Nil(t, raw)
+			strictErr, ok := err.(*StrictError)
+			require.True(t, ok)
+			require.Equal(t, tc.unknown, strictErr.Unknown)
+			require.Equal(t, tc.duplicate, strictErr.Duplicate)
+		})
+	}
+}
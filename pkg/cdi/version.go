@@ -0,0 +1,221 @@
+package cdi
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+
+	cdi "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// CurrentVersion is the current version of the CDI Spec.
+	CurrentVersion = cdi.CurrentVersion
+
+	// vCurrent is the current version as a comparable version.
+	vCurrent version = "v" + CurrentVersion
+
+	// These are the released versions of the CDI Spec.
+	v030 version = "v0.3.0"
+	v040 version = "v0.4.0"
+	v050 version = "v0.5.0"
+	v060 version = "v0.6.0"
+	v070 version = "v0.7.0"
+	v080 version = "v0.8.0"
+
+	// vEarliest is the earliest supported version of the CDI Spec.
+	vEarliest version = v030
+)
+
+// validSpecVersions maps Spec versions to functions checking whether a
+// Spec needs at least that version. Adding new fields to the Spec needs
+// a new version and a corresponding check here.
+var validSpecVersions = requiredVersionMap{
+	v030: nil,
+	v040: requiresV040,
+	v050: requiresV050,
+	v060: requiresV060,
+	v070: requiresV070,
+	v080: requiresV080,
+}
+
+// MinimumRequiredVersion returns the minimum Spec version required by
+// the features used in the given raw CDI Spec.
+func MinimumRequiredVersion(raw *cdi.Spec) (string, error) {
+	if raw == nil {
+		return "", fmt.Errorf("nil CDI Spec")
+	}
+	return validSpecVersions.requiredVersion(raw).String(), nil
+}
+
+// UpdateSpecVersion sets the version of the given raw CDI Spec. It fails
+// if the version is unknown or if the Spec uses features which the
+// version lacks, which would be lost by the update.
+func UpdateSpecVersion(raw *cdi.Spec, specVersion string) error {
+	if err := validateVersion(specVersion); err != nil {
+		return err
+	}
+	minVersion, err := MinimumRequiredVersion(raw)
+	if err != nil {
+		return err
+	}
+	if newVersion(minVersion).IsGreaterThan(newVersion(specVersion)) {
+		return fmt.Errorf("can't set CDI Spec version %s, Spec requires at least %s",
+			specVersion, minVersion)
+	}
+	raw.Version = newVersion(specVersion).String()
+	return nil
+}
+
+// version is a semantic Spec version with a leading 'v'.
+type version string
+
+// newVersion returns the comparable version for the given version string.
+func newVersion(v string) version {
+	return version("v" + strings.TrimPrefix(v, "v"))
+}
+
+// String returns the version without the leading 'v'.
+func (v version) String() string {
+	return strings.TrimPrefix(string(v), "v")
+}
+
+// IsGreaterThan checks if the version is greater than the other one.
+func (v version) IsGreaterThan(o version) bool {
+	a, b := v.parts(), o.parts()
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+// IsLatest checks if the version is the current version.
+func (v version) IsLatest() bool {
+	return v == vCurrent
+}
+
+// parts returns the major, minor and patch numbers of the version.
+func (v version) parts() [3]int {
+	var parts [3]int
+	for i, s := range strings.SplitN(v.String(), ".", 3) {
+		parts[i], _ = strconv.Atoi(s)
+	}
+	return parts
+}
+
+type requiredFunc func(*cdi.Spec) bool
+
+type requiredVersionMap map[version]requiredFunc
+
+// isValidVersion checks if the given version is a known Spec version.
+func (r requiredVersionMap) isValidVersion(specVersion string) bool {
+	_, ok := r[newVersion(specVersion)]
+	return ok
+}
+
+// requiredVersion returns the minimum version required for the Spec.
+func (r requiredVersionMap) requiredVersion(raw *cdi.Spec) version {
+	minVersion := vEarliest
+
+	for v, isRequired := range r {
+		if isRequired == nil {
+			continue
+		}
+		if isRequired(raw) && v.IsGreaterThan(minVersion) {
+			minVersion = v
+		}
+		if minVersion.IsLatest() {
+			break
+		}
+	}
+
+	return minVersion
+}
+
+// requiresV080 checks if the Spec uses v0.8.0 features.
+func requiresV080(raw *cdi.Spec) bool {
+	// conditional container edits were added in v0.8.0
+	if len(raw.ContainerEdits.Conditional) > 0 {
+		return true
+	}
+	for _, d := range raw.Devices {
+		if len(d.ContainerEdits.Conditional) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// requiresV070 checks if the Spec uses v0.7.0 features.
+func requiresV070(raw *cdi.Spec) bool {
+	// alias and composite devices and device requires and conflicts
+	// were added in v0.7.0
+	for _, d := range raw.Devices {
+		if d.AliasOf != "" || len(d.Members) > 0 || len(d.Requires) > 0 || len(d.Conflicts) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// requiresV060 checks if the Spec uses v0.6.0 features.
+func requiresV060(raw *cdi.Spec) bool {
+	// spec- and device-level annotations were added in v0.6.0
+	if len(raw.Annotations) > 0 {
+		return true
+	}
+	for _, d := range raw.Devices {
+		if len(d.Annotations) > 0 {
+			return true
+		}
+	}
+
+	// dots in the class name are allowed since v0.6.0
+	vendor, class := parser.ParseQualifier(raw.Kind)
+	return vendor != "" && strings.ContainsRune(class, '.')
+}
+
+// requiresV050 checks if the Spec uses v0.5.0 features.
+func requiresV050(raw *cdi.Spec) bool {
+	edits := []*cdi.ContainerEdits{&raw.ContainerEdits}
+
+	for i, d := range raw.Devices {
+		// device names may start with a digit since v0.5.0
+		if d.Name != "" && (d.Name[0] >= '0' && d.Name[0] <= '9') {
+			return true
+		}
+		edits = append(edits, &raw.Devices[i].ContainerEdits)
+	}
+
+	for _, e := range edits {
+		for _, dn := range e.DeviceNodes {
+			// HostPath was added in v0.5.0
+			if dn != nil && dn.HostPath != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// requiresV040 checks if the Spec uses v0.4.0 features.
+func requiresV040(raw *cdi.Spec) bool {
+	edits := []*cdi.ContainerEdits{&raw.ContainerEdits}
+	for i := range raw.Devices {
+		edits = append(edits, &raw.Devices[i].ContainerEdits)
+	}
+
+	for _, e := range edits {
+		for _, m := range e.Mounts {
+			// mount Type was added in v0.4.0
+			if m != nil && m.Type != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+// Package deviceplugin translates resolved CDI devices into Kubernetes
+// device plugin allocate responses. The response types mirror those of
+// the device plugin API, so translation can be tested without kubelet.
+package deviceplugin
+
+import (
+	"fmt"
+	"strings"
+
+	"container-device-interface-aaron/pkg/cdi"
+	specs "container-device-interface-aaron/specs-go"
+)
+
+const (
+	// defaultPermissions are the cgroup permissions of device nodes
+	// which declare none.
+	defaultPermissions = "rwm"
+)
+
+// ContainerAllocateResponse mirrors the device plugin API response for
+// a single container. It carries both the CDI device names for CDI-aware
+// kubelets and runtimes, and the legacy env, mounts and device specs for
+// older ones.
+type ContainerAllocateResponse struct {
+	// Envs are the environment variables to set in the container.
+	Envs map[string]string `json:"envs,omitempty"`
+	// Mounts are the host paths to mount into the container.
+	Mounts []*Mount `json:"mounts,omitempty"`
+	// Devices are the host devices to expose to the container.
+	Devices []*DeviceSpec `json:"devices,omitempty"`
+	// Annotations are the container annotations, carrying the CDI
+	// device injection request.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// CDIDevices are the qualified names of the CDI devices.
+	CDIDevices []*CDIDevice `json:"cdiDevices,omitempty"`
+}
+
+// Mount mirrors the device plugin API mount.
+type Mount struct {
+	ContainerPath string `json:"containerPath"`
+	HostPath      string `json:"hostPath"`
+	ReadOnly      bool   `json:"readOnly"`
+}
+
+// DeviceSpec mirrors the device plugin API device spec.
+type DeviceSpec struct {
+	ContainerPath string `json:"containerPath"`
+	HostPath      string `json:"hostPath"`
+	Permissions   string `json:"permissions"`
+}
+
+// CDIDevice mirrors the device plugin API CDI device.
+type CDIDevice struct {
+	Name string `json:"name"`
+}
+
+// NewContainerAllocateResponse translates CDI devices into an allocate
+// response. plugin and allocationID form the CDI annotation key, see
+// cdi.UpdateAnnotations. The legacy fields are the edits injecting the
+// devices with the cache would apply, see cdi.Cache.GetInjectedEdits.
+// Hooks and non-bind mounts have no legacy equivalent, they are only
+// injected by CDI-aware runtimes. Since the legacy env variables can't
+// be ordered, setting a variable to different values is an error.
+func NewContainerAllocateResponse(cache *cdi.Cache, plugin, allocationID string, devices ...string) (*ContainerAllocateResponse, error) {
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("no CDI devices to allocate")
+	}
+
+	edits, _, err := cache.GetInjectedEdits(devices...)
+	if err != nil {
+		return nil, err
+	}
+
+	annotations, err := cdi.UpdateAnnotations(nil, plugin, allocationID, devices)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &ContainerAllocateResponse{
+		Annotations: annotations,
+	}
+	for _, name := range devices {
+		r.CDIDevices = append(r.CDIDevices, &CDIDevice{Name: name})
+	}
+	for _, e := range edits.Env {
+		if r.Envs == nil {
+			r.Envs = map[string]string{}
+		}
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("invalid environment variable %q", e)
+		}
+		if v, ok := r.Envs[kv[0]]; ok && v != kv[1] {
+			return nil, fmt.Errorf("conflicting values %q and %q for environment variable %q",
+				v, kv[1], kv[0])
+		}
+		r.Envs[kv[0]] = kv[1]
+	}
+	for _, d := range edits.DeviceNodes {
+		if d != nil {
+			r.addDevice(d)
+		}
+	}
+	for _, m := range edits.Mounts {
+		if m != nil {
+			r.addMount(m)
+		}
+	}
+
+	return r, nil
+}
+
+// addDevice adds a device spec for a CDI device node, replacing any
+// device spec for the same container path.
+func (r *ContainerAllocateResponse) addDevice(d *specs.DeviceNode) {
+	dev := &DeviceSpec{
+		ContainerPath: d.Path,
+		HostPath:      d.HostPath,
+		Permissions:   d.Permissions,
+	}
+	if dev.HostPath == "" {
+		dev.HostPath = d.Path
+	}
+	if dev.Permissions == "" {
+		dev.Permissions = defaultPermissions
+	}
+
+	for i, o := range r.Devices {
+		if o.ContainerPath == dev.ContainerPath {
+			r.Devices[i] = dev
+			return
+		}
+	}
+	r.Devices = append(r.Devices, dev)
+}
+
+// addMount adds a mount for a CDI bind mount, replacing any mount for
+// the same container path. Other mounts are skipped.
+func (r *ContainerAllocateResponse) addMount(m *specs.Mount) {
+	if m.Type != "" && m.Type != "bind" {
+		return
+	}
+
+	mnt := &Mount{
+		ContainerPath: m.ContainerPath,
+		HostPath:      m.HostPath,
+	}
+	for _, o := range m.Options {
+		if o == "ro" {
+			mnt.ReadOnly = true
+		}
+	}
+
+	for i, o := range r.Mounts {
+		if o.ContainerPath == mnt.ContainerPath {
+			r.Mounts[i] = mnt
+			return
+		}
+	}
+	r.Mounts = append(r.Mounts, mnt)
+}
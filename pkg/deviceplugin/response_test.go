@@ -0,0 +1,116 @@
+package deviceplugin
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+func TestNewContainerAllocateResponse(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "vendor.yaml"), []byte(`cdiVersion: "0.8.0"
+kind: vendor.com/gpu
+containerEdits:
+  env: ["VENDOR_DRIVER=1"]
+  mounts:
+    - hostPath: /usr/lib/libvendor.so.1
+      containerPath: /usr/lib/libvendor.so
+      options: ["ro", "nosuid", "nodev", "bind"]
+    - hostPath: tmpfs
+      containerPath: /var/run/vendor
+      type: tmpfs
+  hooks:
+    - hookName: createContainer
+      path: /usr/bin/vendor-hook
+devices:
+  - name: ctl
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendorctl
+          type: c
+          major: 195
+          minor: 255
+  - name: gpu0
+    requires: ["ctl"]
+    containerEdits:
+      env: ["VENDOR_GPU0=1"]
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+  - name: gpu1
+    containerEdits:
+      env: ["VENDOR_GPU1=1"]
+      deviceNodes:
+        - path: /dev/vendor1
+          hostPath: /dev/vendor-renamed1
+          type: c
+          major: 195
+          minor: 1
+          permissions: rw
+      mounts:
+        - hostPath: /var/lib/vendor/gpu1
+          containerPath: /var/lib/vendor
+      conditional:
+        - ifExists: `+dir+`
+          containerEdits:
+            env: ["VENDOR_PRESENT=1"]
+        - ifExists: `+filepath.Join(dir, "missing")+`
+          containerEdits:
+            env: ["VENDOR_MISSING=1"]
+  - name: gpu0-mig
+    containerEdits:
+      env: ["VENDOR_GPU0=mig"]
+      deviceNodes:
+        - path: /dev/vendor0-mig
+          type: c
+          major: 195
+          minor: 128
+`), 0o644))
+
+	cache, err := cdi.NewCache(cdi.WithSpecDirs(dir))
+	require.NoError(t, err)
+	require.Empty(t, cache.GetErrors())
+
+	r, err := NewContainerAllocateResponse(cache, "vendor.gpu", "alloc0",
+		"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1")
+	require.NoError(t, err)
+	require.Equal(t, &ContainerAllocateResponse{
+		Envs: map[string]string{
+			"VENDOR_DRIVER":  "1",
+			"VENDOR_GPU0":    "1",
+			"VENDOR_GPU1":    "1",
+			"VENDOR_PRESENT": "1",
+		},
+		Mounts: []*Mount{
+			{ContainerPath: "/usr/lib/libvendor.so", HostPath: "/usr/lib/libvendor.so.1", ReadOnly: true},
+			{ContainerPath: "/var/lib/vendor", HostPath: "/var/lib/vendor/gpu1"},
+		},
+		Devices: []*DeviceSpec{
+			{ContainerPath: "/dev/vendor0", HostPath: "/dev/vendor0", Permissions: "rwm"},
+			{ContainerPath: "/dev/vendor1", HostPath: "/dev/vendor-renamed1", Permissions: "rw"},
+			{ContainerPath: "/dev/vendorctl", HostPath: "/dev/vendorctl", Permissions: "rwm"},
+		},
+		Annotations: map[string]string{
+			"cdi.k8s.io/vendor.gpu_alloc0": "vendor.com/gpu=gpu0,vendor.com/gpu=gpu1",
+		},
+		CDIDevices: []*CDIDevice{
+			{Name: "vendor.com/gpu=gpu0"},
+			{Name: "vendor.com/gpu=gpu1"},
+		},
+	}, r)
+
+	_, err = NewContainerAllocateResponse(cache, "vendor.gpu", "alloc0")
+	require.Error(t, err)
+	_, err = NewContainerAllocateResponse(cache, "vendor.gpu", "alloc0", "vendor.com/gpu=gpu2")
+	require.Error(t, err)
+	_, err = NewContainerAllocateResponse(cache, "vendor.gpu", "alloc/0-", "vendor.com/gpu=gpu0")
+	require.Error(t, err)
+	_, err = NewContainerAllocateResponse(cache, "vendor.gpu", "alloc0",
+		"vendor.com/gpu=gpu0", "vendor.com/gpu=gpu0-mig")
+	require.Error(t, err)
+}
@@ -0,0 +1,198 @@
+// Package nri implements CDI device injection as an NRI (Node Resource
+// Interface) style plugin. Instead of patching every runtime, the runtime
+// asks the plugin for a container adjustment when it creates a container,
+// and the plugin answers with the container edits of the requested CDI
+// devices.
+//
+// The plugin connects to the runtime over a unix socket, then serves
+// JSON-RPC requests from the runtime over that connection.
+package nri
+
+import (
+	"fmt"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"strings"
+
+	"github.com/container-orchestrated-devices/container-device-interface/pkg/parser"
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+const (
+	// DefaultSocketPath is the default runtime NRI socket.
+	DefaultSocketPath = "/var/run/nri/nri.sock"
+	// ServiceName is the RPC service name of the plugin.
+	ServiceName = "Plugin"
+	// ContainerAnnotationPrefix is the prefix of pod annotations requesting
+	// CDI devices for a single container, followed by the container name.
+	ContainerAnnotationPrefix = "cdi-devices.nri.io/container."
+)
+
+// PodSandbox is the pod of a container being created.
+type PodSandbox struct {
+	ID          string            `json:"id"`
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Container is a container being created.
+type Container struct {
+	ID           string            `json:"id"`
+	PodSandboxID string            `json:"podSandboxId"`
+	Name         string            `json:"name"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+// CreateContainerRequest is sent by the runtime when creating a container.
+type CreateContainerRequest struct {
+	Pod       *PodSandbox `json:"pod"`
+	Container *Container  `json:"container"`
+}
+
+// CreateContainerResponse is the plugin response to container creation.
+type CreateContainerResponse struct {
+	Adjust *ContainerAdjustment `json:"adjust,omitempty"`
+}
+
+// ContainerAdjustment are the changes the runtime makes to a container
+// being created. Environment variables override existing ones by name,
+// devices and mounts replace existing ones with the same container path,
+// device rules and hooks are appended.
+type ContainerAdjustment struct {
+	Env         []string                `json:"env,omitempty"`
+	Mounts      []oci.Mount             `json:"mounts,omitempty"`
+	Devices     []oci.LinuxDevice       `json:"devices,omitempty"`
+	DeviceRules []oci.LinuxDeviceCgroup `json:"deviceRules,omitempty"`
+	Hooks       *oci.Hooks              `json:"hooks,omitempty"`
+}
+
+// Plugin injects CDI devices through container adjustments.
+type Plugin struct {
+	cache *cdi.Cache
+}
+
+// NewPlugin creates a plugin resolving devices with the given cache.
+// The plugin never refreshes the cache itself, create it with the
+// WithAutoRefresh option to pick up Spec changes.
+func NewPlugin(cache *cdi.Cache) *Plugin {
+	return &Plugin{
+		cache: cache,
+	}
+}
+
+// Run connects to the runtime at the given socket and serves its
+// requests until the connection is closed.
+func (p *Plugin) Run(socketPath string) error {
+	conn, err := net.Dial("unix", socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to connect to runtime: %w", err)
+	}
+	return p.ServeConn(conn)
+}
+
+// ServeConn serves runtime requests on the given connection until it is
+// closed.
+func (p *Plugin) ServeConn(conn net.Conn) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(ServiceName, &service{p}); err != nil {
+		conn.Close()
+		return err
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+// CreateContainer returns the adjustment injecting the CDI devices
+// requested for the container. Containers requesting no devices get no
+// adjustment.
+func (p *Plugin) CreateContainer(req *CreateContainerRequest) (*CreateContainerResponse, error) {
+	devices, err := RequestedDevices(req.Pod, req.Container)
+	if err != nil {
+		return nil, err
+	}
+	if len(devices) == 0 {
+		return &CreateContainerResponse{}, nil
+	}
+
+	adjust, err := p.adjust(devices)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inject CDI devices into container %q: %w",
+			req.Container.Name, err)
+	}
+	return &CreateContainerResponse{Adjust: adjust}, nil
+}
+
+// adjust resolves the devices and translates their container edits into
+// an adjustment. The edits are applied to an empty OCI Spec, so the
+// adjustment matches exactly what injection into the container would do.
+func (p *Plugin) adjust(devices []string) (*ContainerAdjustment, error) {
+	spec := &oci.Spec{}
+	if _, err := p.cache.InjectDevices(spec, devices...); err != nil {
+		return nil, err
+	}
+
+	adjust := &ContainerAdjustment{
+		Mounts: spec.Mounts,
+		Hooks:  spec.Hooks,
+	}
+	if spec.Process != nil {
+		adjust.Env = spec.Process.Env
+	}
+	if spec.Linux != nil {
+		adjust.Devices = spec.Linux.Devices
+		if spec.Linux.Resources != nil {
+			adjust.DeviceRules = spec.Linux.Resources.Devices
+		}
+	}
+	return adjust, nil
+}
+
+// RequestedDevices returns the CDI devices requested for a container,
+// by CDI annotations of the container and by the per-container pod
+// annotation.
+func RequestedDevices(pod *PodSandbox, ctr *Container) ([]string, error) {
+	if ctr == nil {
+		return nil, fmt.Errorf("missing container")
+	}
+
+	_, devices, err := cdi.ParseAnnotations(ctr.Annotations)
+	if err != nil {
+		return nil, err
+	}
+
+	if pod != nil {
+		key := ContainerAnnotationPrefix + ctr.Name
+		if value, ok := pod.Annotations[key]; ok {
+			for _, d := range strings.Split(value, ",") {
+				if d = strings.TrimSpace(d); d == "" {
+					continue
+				}
+				if !parser.IsQualifiedName(d) {
+					return nil, fmt.Errorf("invalid CDI device name %q in annotation %q", d, key)
+				}
+				devices = append(devices, d)
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+// service exposes the plugin over RPC.
+type service struct {
+	p *Plugin
+}
+
+// CreateContainer handles container creation requests.
+func (s *service) CreateContainer(req *CreateContainerRequest, resp *CreateContainerResponse) error {
+	r, err := s.p.CreateContainer(req)
+	if err != nil {
+		return err
+	}
+	*resp = *r
+	return nil
+}
@@ -0,0 +1,174 @@
+package nri
+
+import (
+	"io/ioutil"
+	"net"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"testing"
+
+	oci "github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+
+	"container-device-interface-aaron/pkg/cdi"
+)
+
+func TestPlugin(t *testing.T) {
+	specDir := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(specDir, "vendor.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/gpu
+containerEdits:
+  env: ["VENDOR_DRIVER=1"]
+  hooks:
+    - hookName: createContainer
+      path: /usr/bin/vendor-hook
+      args: ["vendor-hook", "update-ldcache"]
+devices:
+  - name: gpu0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/vendor0
+          type: c
+          major: 195
+          permissions: rw
+      mounts:
+        - hostPath: /usr/lib/libvendor.so
+          containerPath: /usr/lib/libvendor.so
+          options: ["ro", "bind"]
+`), 0o644))
+	// a broken Spec file must not fail injection of unrelated devices
+	require.NoError(t, ioutil.WriteFile(filepath.Join(specDir, "broken.yaml"), []byte(`cdiVersion: "0.5.0"
+kind: vendor.com/broken
+devices:
+  - name: "-invalid-"
+`), 0o644))
+
+	cache, err := cdi.NewCache(cdi.WithSpecDirs(specDir))
+	require.NoError(t, err)
+	require.Len(t, cache.GetErrors(), 1)
+
+	// unix socket paths are short, avoid the long test temp dir path
+	sockDir, err := os.MkdirTemp("", "nri")
+	require.NoError(t, err)
+	defer os.RemoveAll(sockDir)
+	socketPath := filepath.Join(sockDir, "nri.sock")
+
+	// stand-in runtime: accept the plugin connection and drive it
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	defer l.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewPlugin(cache).Run(socketPath)
+	}()
+
+	conn, err := l.Accept()
+	require.NoError(t, err)
+	client := jsonrpc.NewClient(conn)
+
+	pod := &PodSandbox{
+		ID:   "pod0",
+		Name: "pod",
+		Annotations: map[string]string{
+			ContainerAnnotationPrefix + "ctr1": "vendor.com/gpu=gpu0",
+		},
+	}
+
+	resp := &CreateContainerResponse{}
+	require.NoError(t, client.Call(ServiceName+".CreateContainer", &CreateContainerRequest{
+		Pod:       pod,
+		Container: &Container{ID: "ctr0", Name: "ctr0"},
+	}, resp))
+	require.Nil(t, resp.Adjust)
+
+	major, minor := int64(195), int64(0)
+	resp = &CreateContainerResponse{}
+	require.NoError(t, client.Call(ServiceName+".CreateContainer", &CreateContainerRequest{
+		Pod:       pod,
+		Container: &Container{ID: "ctr1", Name: "ctr1"},
+	}, resp))
+	require.Equal(t, &ContainerAdjustment{
+		Env: []string{"VENDOR_DRIVER=1"},
+		Mounts: []oci.Mount{
+			{
+				Source:      "/usr/lib/libvendor.so",
+				Destination: "/usr/lib/libvendor.so",
+				Options:     []string{"ro", "bind"},
+			},
+		},
+		Devices: []oci.LinuxDevice{
+			{Path: "/dev/vendor0", Type: "c", Major: 195},
+		},
+		DeviceRules: []oci.LinuxDeviceCgroup{
+			{Allow: true, Type: "c", Major: &major, Minor: &minor, Access: "rw"},
+		},
+		Hooks: &oci.Hooks{
+			CreateContainer: []oci.Hook{
+				{Path: "/usr/bin/vendor-hook", Args: []string{"vendor-hook", "update-ldcache"}},
+			},
+		},
+	}, resp.Adjust)
+
+	err = client.Call(ServiceName+".CreateContainer", &CreateContainerRequest{
+		Pod: pod,
+		Container: &Container{
+			ID:          "ctr2",
+			Name:        "ctr2",
+			Annotations: map[string]string{cdi.AnnotationPrefix + "manual": "vendor.com/gpu=gpu1"},
+		},
+	}, &CreateContainerResponse{})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "unresolvable CDI devices vendor.com/gpu=gpu1")
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
+
+func TestRequestedDevices(t *testing.T) {
+	type testCase struct {
+		name       string
+		pod        map[string]string
+		ctr        map[string]string
+		devices    []string
+		shouldFail bool
+	}
+	for _, tc := range []*testCase{
+		{
+			name: "container and pod annotations",
+			pod:  map[string]string{ContainerAnnotationPrefix + "ctr": " vendor.com/gpu=gpu0, ,vendor.com/gpu=gpu1"},
+			ctr:  map[string]string{cdi.AnnotationPrefix + "manual": "vendor.com/gpu=ctl"},
+			devices: []string{
+				"vendor.com/gpu=ctl", "vendor.com/gpu=gpu0", "vendor.com/gpu=gpu1",
+			},
+		},
+		{
+			name: "other container",
+			pod:  map[string]string{ContainerAnnotationPrefix + "other": "vendor.com/gpu=gpu0"},
+		},
+		{
+			name:       "unqualified pod annotation device",
+			pod:        map[string]string{ContainerAnnotationPrefix + "ctr": "gpu0"},
+			shouldFail: true,
+		},
+		{
+			name:       "invalid pod annotation device",
+			pod:        map[string]string{ContainerAnnotationPrefix + "ctr": "vendor.com/gpu=-gpu0"},
+			shouldFail: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			devices, err := RequestedDevices(
+				&PodSandbox{Name: "pod", Annotations: tc.pod},
+				&Container{Name: "ctr", Annotations: tc.ctr},
+			)
+			if tc.shouldFail {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.devices, devices)
+		})
+	}
+}
@@ -1,7 +1,6 @@
 package schema
 
 import (
-	"bytes"
 	"embed"
 	"encoding/json"
 	"fmt"
@@ -11,12 +10,12 @@ import (
 	"path/filepath"
 	"strings"
 
+	schema "github.com/xeipuuv/gojsonschema"
 	"sigs.k8s.io/yaml"
 
-	"github.com/container-orchestrated-devices/container-device-interface/internal/multierror"
-
-	"github.com/container-orchestrated-devices/container-device-interface/internal/validation"
-	schema "github.com/xeipuuv/gojsonschema"
+	"container-device-interface-aaron/internal/multierror"
+	"container-device-interface-aaron/internal/stream"
+	"container-device-interface-aaron/internal/validation"
 )
 
 const (
@@ -25,7 +24,7 @@ const (
 	// NoneSchemaName names the NOP-schema for Load()/Set().
 	NoneSchemaName = "none"
 	// builtinSchemaFile is the builtin schema URI in our embedded FS
-	builtinSchemaFile = "file://schema.json"
+	builtinSchemaFile = "file:///schema.json"
 )
 
 // Schema is a JSON schema.
@@ -38,6 +37,10 @@ type Error struct {
 	Result *schema.Result
 }
 
+// DocumentError is a validation error for a single document of a
+// multi-document YAML or JSON Lines stream.
+type DocumentError = stream.Error
+
 // Get returns the active validating JSON schema.
 func Set(s *Schema) {
 	current = s
@@ -90,6 +93,12 @@ func ValidateData(data []byte) error {
 	return current.ValidateData(data)
 }
 
+// ValidateStream validates each document of the given stream against
+// the active schema.
+func ValidateStream(data []byte) []error {
+	return current.ValidateStream(data)
+}
+
 // ValidateFile validates the given JSON file against the active schema.
 func ValidateFile(path string) error {
 	return current.ValidateFile(path)
@@ -154,14 +163,47 @@ func (s *Schema) Validate(r io.Reader) error {
 	return err
 }
 
-// ValidateData validates the given JSON data agaisnt the schema.
+// ValidateData validates the given JSON data agaisnt the schema. If
+// data is a multi-document YAML or JSON Lines stream, every document
+// is validated and the errors of all invalid documents are returned.
 func (s *Schema) ValidateData(data []byte) error {
+	if docs := stream.Split(data); len(docs) > 1 {
+		var multi error
+		for _, err := range s.validateDocuments(docs) {
+			multi = multierror.Append(multi, err)
+		}
+		return multi
+	}
+	return s.validateData(data)
+}
+
+// ValidateStream validates each document of a multi-document YAML or
+// JSON Lines stream independently against the schema. It returns a
+// *DocumentError for each invalid document, or nil if all documents
+// are valid.
+func (s *Schema) ValidateStream(data []byte) []error {
+	return s.validateDocuments(stream.Split(data))
+}
+
+// validateDocuments validates the given documents against the schema.
+func (s *Schema) validateDocuments(docs []*stream.Document) []error {
+	var errs []error
+	for _, doc := range docs {
+		if err := s.validateData(doc.Data); err != nil {
+			errs = append(errs, doc.Wrap(err))
+		}
+	}
+	return errs
+}
+
+// validateData validates a single JSON or YAML document against the schema.
+func (s *Schema) validateData(data []byte) error {
 	var (
 		any map[string]interface{}
 		err error
 	)
 
-	if !bytes.HasPrefix(bytes.TrimSpace(data), []byte{'{'}) {
+	if !stream.IsJSON(data) || !json.Valid(data) {
 		err = yaml.Unmarshal(data, &any)
 		if err != nil {
 			return fmt.Errorf("failed to YAML unmarshal data for validation: %w", err)
@@ -1,4 +1,4 @@
-package schema
+package schema_test
 
 import (
 	"bytes"
@@ -11,8 +11,9 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/container-orchestrated-devices/container-device-interface/pkg/cdi"
 	"github.com/stretchr/testify/require"
+
+	"container-device-interface-aaron/pkg/cdi"
 )
 
 var (
@@ -75,7 +76,7 @@ func TestValidateFile(t *testing.T) {
 
 			scanAndValidate(t, scm, "./testdata/good", true, validateFile)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateFile)
-			old := schema.Get(scm)
+			old := schema.Get()
 			schema.Set(scm)
 			scanAndValidate(t, scm, "./testdata/good", true, validateFile)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateFile)
@@ -108,7 +109,7 @@ func TestValidateData(t *testing.T) {
 
 			scanAndValidate(t, scm, "./testdata/good", true, validateData)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateData)
-			old := schema.Get(scm)
+			old := schema.Get()
 			schema.Set(scm)
 			scanAndValidate(t, scm, "./testdata/good", true, validateData)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateData)
@@ -141,7 +142,7 @@ func TestValidateReader(t *testing.T) {
 
 			scanAndValidate(t, scm, "./testdata/good", true, validateRead)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateRead)
-			old := schema.Get(scm)
+			old := schema.Get()
 			schema.Set(scm)
 			scanAndValidate(t, scm, "./testdata/good", true, validateRead)
 			scanAndValidate(t, scm, "./testdata/bad", false, validateRead)
@@ -277,7 +278,7 @@ func validateRead(t *testing.T, scm *schema.Schema, path string, shouldLoad, isV
 		err = schema.Validate(r)
 	}
 
-	verifyResult(t, scm, shouldLoad, isValid)
+	verifyResult(t, scm, err, shouldLoad, isValid)
 
 	if scm != nil {
 		err = scm.Validate(bytes.NewReader(buf.Bytes()))
@@ -356,3 +357,80 @@ func verifyResult(t *testing.T, s *schema.Schema, err error, shouldLoad, isValid
 		require.NoError(t, err)
 	}
 }
+
+func TestValidateStream(t *testing.T) {
+	type testCase struct {
+		testName string
+		data     string
+		invalid  []int
+	}
+	for _, tc := range []*testCase{
+		{
+			testName: "valid YAML stream",
+			data: `---
+cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+---
+cdiVersion: "0.3.0"
+kind: vendor.com/other
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/other0
+`,
+		},
+		{
+			testName: "YAML stream with an invalid document",
+			data: `cdiVersion: "0.3.0"
+kind: vendor.com/device
+devices:
+  - name: dev0
+    containerEdits:
+      deviceNodes:
+        - path: /dev/dev0
+---
+cdiVersion: "0.3.0"
+kind: vendor.com/other
+`,
+			invalid: []int{1},
+		},
+		{
+			testName: "YAML flow style document",
+			data: `{cdiVersion: "0.3.0", kind: vendor.com/device, devices: [{name: dev0, containerEdits: {deviceNodes: [{path: /dev/dev0}]}}]}
+`,
+		},
+		{
+			testName: "JSON Lines with invalid documents",
+			data: `{"cdiVersion": "0.3.0", "kind": "vendor.com/device"}
+{"cdiVersion": "0.3.0", "kind": "vendor.com/device", "devices": [{"name": "dev0", "containerEdits": {"deviceNodes": [{"path": "/dev/dev0"}]}}]}
+{"cdiVersion": "0.3.0", "devices": [{"name": "dev0", "containerEdits": {"deviceNodes": [{"path": "/dev/dev0"}]}}]}
+`,
+			invalid: []int{0, 2},
+		},
+	} {
+		t.Run(tc.testName, func(t *testing.T) {
+			scm := loadSchema(t, "builtin")
+
+			errs := scm.ValidateStream([]byte(tc.data))
+			require.Len(t, errs, len(tc.invalid))
+			for i, err := range errs {
+				docErr, ok := err.(*schema.DocumentError)
+				require.True(t, ok)
+				require.Equal(t, tc.invalid[i], docErr.Index)
+			}
+
+			err := scm.ValidateData([]byte(tc.data))
+			if len(tc.invalid) > 0 {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
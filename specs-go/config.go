@@ -4,7 +4,7 @@ import "os"
 
 // Version of the spec. Putting the same as Nvidia's for now.
 
-const CurrentVersion = "0.6.0"
+const CurrentVersion = "0.8.0"
 
 // Spec is the base configuration for CDI
 
@@ -25,6 +25,14 @@ type Device struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 	// ContainerEdits are the edits to the OCI spec that are required for this device
 	ContainerEdits ContainerEdits `json:"containerEdits"`
+	// AliasOf is the name of another device in the same spec. An alias device injects the edits of that device.
+	AliasOf string `json:"aliasOf,omitempty"`
+	// Members are the names of other devices in the same spec. A composite device injects the edits of all its members, followed by its own edits.
+	Members []string `json:"members,omitempty"`
+	// Requires are the names of devices which must be injected together with this device. Unqualified names refer to devices in the same spec.
+	Requires []string `json:"requires,omitempty"`
+	// Conflicts are the names of devices which must never be injected together with this device. Unqualified names refer to devices in the same spec.
+	Conflicts []string `json:"conflicts,omitempty"`
 }
 
 // ContainerEdits are edits a container runtime must make to the OCI spec to expose the device
@@ -33,6 +41,20 @@ type ContainerEdits struct {
 	DeviceNodes []*DeviceNode `json:"deviceNodes,omitempty"`
 	Hooks       []*Hook       `json:"hooks,omitempty"`
 	Mounts      []*Mount      `json:"mounts,omitempty"`
+	// Conditional are edits which are only applied if their conditions hold on the host
+	Conditional []*ConditionalEdits `json:"conditional,omitempty"`
+}
+
+// ConditionalEdits are edits which are only applied if all of their conditions hold on the host
+type ConditionalEdits struct {
+	// Arch is the list of host architectures the edits apply to
+	Arch []string `json:"arch,omitempty"`
+	// MinKernelVersion is the minimum host kernel version the edits apply to
+	MinKernelVersion string `json:"minKernelVersion,omitempty"`
+	// IfExists is a host path which must exist for the edits to apply
+	IfExists string `json:"ifExists,omitempty"`
+	// ContainerEdits are the edits applied if all conditions hold
+	ContainerEdits ContainerEdits `json:"containerEdits"`
 }
 
 // DeviceNode represents a device node that needs to be added to the OCI spec
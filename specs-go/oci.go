@@ -72,7 +72,7 @@ func ApplyEditsToOCISpec(config *spec.Spec, edits *ContainerEdits) error {
 		case "poststop":
 			config.Hooks.Poststop = append(config.Hooks.Poststop, h.ToOCI())
 		default:
-			fmt.Errorf("CDI: unknown hook %q\n", h.HookName)
+			// unknown hooks are ignored
 		}
 	}
 
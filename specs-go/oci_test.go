@@ -397,7 +397,7 @@ func TestApplyOCIEditsForDevice(t *testing.T) {
 				Kind:    "vendor.com/device",
 				Devices: []Device{
 					{
-						Name: "Vendor device XYZ",
+						Name: "Vendor device ABC",
 						ContainerEdits: ContainerEdits{
 							DeviceNodes: []*DeviceNode{
 								{